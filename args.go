@@ -4,27 +4,55 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
 type Arguments struct {
-	Arg  string
-	Join bool
-	Help bool
-	Cat  bool
+	Arg      string
+	Join     bool
+	Help     bool
+	Cat      bool
+	Follow   bool
+	Format   string
+	At       string
+	Record   string
+	Replay   bool
+	Speed    float64
+	Blame    bool
+	Export   bool
+	Out      string
+	Timeline bool
+	Meta     bool
 }
 
 func usage() {
 	fmt.Println(`usage:
 	sync-edit --join code
+	sync-edit --cat [--follow [--format full|diff|json]] code
+	sync-edit --cat --at time|message-id code
+	sync-edit --cat --meta code
+	sync-edit --replay [--speed n] recording
+	sync-edit --blame code
+	sync-edit --export [--format html|md] [--out file] [--timeline] code
 	sync-edit path/to/file`)
 }
 
 func (a *Arguments) ParseArgs() error {
 	var name *string
+	args := os.Args[1:]
 
-	for _, arg := range os.Args[1:] {
+	value := func(i int) (string, error) {
+		if i+1 >= len(args) {
+			return "", errors.New(fmt.Sprintf("argument %s needs a value", args[i]))
+		}
+		return args[i+1], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		if strings.HasPrefix(arg, "-") {
+			var err error
 			switch arg {
 			case "--join", "-j":
 				a.Join = true
@@ -32,11 +60,45 @@ func (a *Arguments) ParseArgs() error {
 				a.Help = true
 			case "--cat", "-c":
 				a.Cat = true
+			case "--follow", "-f":
+				a.Follow = true
+			case "--format":
+				a.Format, err = value(i)
+				i++
+			case "--at":
+				a.At, err = value(i)
+				i++
+			case "--record":
+				a.Record, err = value(i)
+				i++
+			case "--replay", "-r":
+				a.Replay = true
+			case "--blame", "-b":
+				a.Blame = true
+			case "--export", "-e":
+				a.Export = true
+			case "--out", "-o":
+				a.Out, err = value(i)
+				i++
+			case "--timeline":
+				a.Timeline = true
+			case "--meta", "-m":
+				a.Meta = true
+			case "--speed":
+				var speed string
+				speed, err = value(i)
+				if err == nil {
+					a.Speed, err = strconv.ParseFloat(speed, 64)
+				}
+				i++
 			default:
 				return errors.New(fmt.Sprintf("unkown argument %s", arg))
 			}
+			if err != nil {
+				return err
+			}
 		} else if name == nil {
-			name = &arg
+			name = &args[i]
 			a.Arg = arg
 		} else {
 			usage()
@@ -44,11 +106,51 @@ func (a *Arguments) ParseArgs() error {
 		}
 	}
 
-	if name == nil && (a.Join || a.Cat) {
+	if name == nil && (a.Join || a.Cat || a.Replay || a.Blame || a.Export) {
 		usage()
 		os.Exit(1)
 	}
 
+	if a.Follow && !a.Cat {
+		return errors.New("--follow can only be used with --cat")
+	}
+
+	if a.Format != "" && !(a.Cat || a.Export) {
+		return errors.New("--format can only be used with --cat or --export")
+	}
+
+	if (a.Out != "" || a.Timeline) && !a.Export {
+		return errors.New("--out and --timeline can only be used with --export")
+	}
+
+	if a.At != "" && (!a.Cat || a.Follow) {
+		return errors.New("--at can only be used with --cat and not --follow")
+	}
+
+	if a.Meta && (!a.Cat || a.Follow || a.At != "") {
+		return errors.New("--meta can only be used with --cat and not --follow or --at")
+	}
+
+	if a.Record != "" && (a.Cat || a.Replay) {
+		return errors.New("--record can't be used with --cat or --replay")
+	}
+
+	if a.Speed != 0 && !a.Replay {
+		return errors.New("--speed can only be used with --replay")
+	}
+
+	formats := []string{"", "full", "diff", "json"}
+	if a.Export {
+		formats = []string{"", "html", "md"}
+	}
+	known := false
+	for _, format := range formats {
+		known = known || a.Format == format
+	}
+	if !known {
+		return errors.New(fmt.Sprintf("unkown format %s", a.Format))
+	}
+
 	return nil
 }
 
@@ -56,6 +158,12 @@ func Help() {
 	fmt.Println(
 		`usage:
     sync-edit --join <session code>
+    sync-edit --cat [--follow] <session code>
+    sync-edit --cat --at <time or message id> <session code>
+    sync-edit --cat --meta <session code>
+    sync-edit --replay [--speed <n>] <recording>
+    sync-edit --blame <session code>
+    sync-edit --export [--format html|md] [--out <file>] <session code>
     sync-edit [path/to/file]
 
     Edit files collaboratively
@@ -64,5 +172,28 @@ func Help() {
 
     -j, --join             Join a session instead of creating one
     -c, --cat              Print the contents of a session
+    -b, --blame            Print the contents of a session with the name of
+                           who last changed each line
+    -f, --follow           With --cat, keep printing the session as it changes
+        --format <format>  How --follow prints changes: full (default), diff
+                           or json
+        --at <point>       With --cat, print the session as it was at a time
+                           (e.g. "2006-01-02 15:04:05" or "15:04") or just
+                           after the message with the given id
+    -m, --meta             With --cat, print the file name, language and
+                           settings of the session as JSON
+    -e, --export           Write the contents of a session coloured by who
+                           wrote each line, with a list of members
+        --format <format>  With --export, html (default) or md
+    -o, --out <file>       With --export, the file to write to instead of
+                           stdout
+        --timeline         With --export, include a timeline of edits
+        --record <file>    Record everything that happens in the session to
+                           a file
+    -r, --replay           Play back a recording made with --record, without
+                           connecting to ably. Space pauses, the left and
+                           right arrows step, [ and ] seek and + and -
+                           change the speed
+        --speed <n>        With --replay, play back n times faster
     -h. --help             Display this help menu`)
 }
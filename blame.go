@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+const blameWidth = 14
+
+// authorName returns the display name of the member with the given ClientID,
+// including members who have since left.
+func (l *Layout) authorName(id string) string {
+	name, ok := l.Names[id]
+	if !ok {
+		return id
+	}
+	return name
+}
+
+func (l *Layout) layoutBlame(gui *gocui.Gui, editor *gocui.View) error {
+	_, maxY := gui.Size()
+
+	blame, err := gui.SetView("blame", 0, 0, blameWidth, maxY-3)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		blame.Title = "Blame"
+	}
+	blame.Clear()
+
+	_, yo := editor.Origin()
+	_, ys := editor.Size()
+	authors := l.Editor.Authors
+	for y := yo; y < yo+ys && y < len(authors); y++ {
+		name := l.authorName(authors[y])
+		if len(name) > blameWidth-1 {
+			name = name[:blameWidth-1]
+		}
+		fmt.Fprintf(blame, "\x1b[0;%dm%s\n", l.memberColour(authors[y])+29, name)
+	}
+	return nil
+}
+
+func (l *Layout) toggleBlame(gui *gocui.Gui, v *gocui.View) error {
+	l.Blame = !l.Blame
+	if !l.Blame {
+		gui.DeleteView("blame")
+	}
+	return nil
+}
+
+// sessionMembers returns the presence data of everyone who has been present
+// in the session, as far back as the presence history goes.
+func sessionMembers(ctx context.Context, channel *ably.RealtimeChannel) (map[string]client.Presence, error) {
+	members := make(map[string]client.Presence)
+
+	rest, err := newREST()
+	if err != nil {
+		return nil, err
+	}
+	history, err := rest.Channels.Get(channel.Name).Presence.History(ably.PresenceHistoryWithDirection(ably.Forwards)).Items(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for history.Next(ctx) {
+		item := history.Item()
+		members[item.ClientID] = client.DecodePresence(item)
+	}
+	if history.Err() != nil {
+		return nil, history.Err()
+	}
+
+	present, err := channel.Presence.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range present {
+		members[member.ClientID] = client.DecodePresence(member)
+	}
+	return members, nil
+}
+
+// replayBlame replays the session's whole history, returning the document and
+// who last changed each line. handle, if not nil, is called for every message.
+// If the start of the session has expired from the history, it falls back to
+// replaying from the latest snapshot, which keeps the authors of each line
+// but not the changes before it.
+func replayBlame(ctx context.Context, channel *ably.RealtimeChannel, handle func(*ably.Message)) ([][]byte, []string, error) {
+	var text [][]byte = [][]byte{{}}
+	var authors []string
+
+	replay := func(msg *ably.Message) {
+		authors = client.Blame(msg, text, authors)
+		text = client.Apply(msg, text)
+		if handle != nil {
+			handle(msg)
+		}
+	}
+
+	err := client.ReplayAll(ctx, channel, replay)
+	if err == client.ErrNoFile {
+		text, authors = [][]byte{{}}, nil
+		err = client.Replay(ctx, channel, replay)
+	}
+	return text, authors, err
+}
+
+func blame(ctx context.Context, channel *ably.RealtimeChannel) error {
+	text, authors, err := replayBlame(ctx, channel, nil)
+	if err != nil {
+		return err
+	}
+
+	members, err := sessionMembers(ctx, channel)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, id := range authors {
+		if len(members[id].Name) > width {
+			width = len(members[id].Name)
+		}
+	}
+
+	for i, line := range text {
+		name := ""
+		if i < len(authors) {
+			name = members[authors[i]].Name
+			if name == "" {
+				name = authors[i]
+			}
+		}
+		fmt.Printf("%-*s %4d | %s\n", width, name, i+1, strings.TrimRight(string(line), "\r"))
+	}
+	return nil
+}
@@ -1,79 +1,153 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
 
+	"github.com/ably-labs/sync-edit/client"
 	"github.com/ably/ably-go/ably"
 )
 
-func cat(ctx context.Context, channel *ably.RealtimeChannel) error {
+type catChange struct {
+	Id        string          `json:"id"`
+	ClientId  string          `json:"clientId"`
+	Name      string          `json:"name"`
+	Timestamp int64           `json:"timestamp"`
+	Op        json.RawMessage `json:"op,omitempty"`
+	Text      string          `json:"text"`
+}
+
+func cat(ctx context.Context, channel *ably.RealtimeChannel, args *Arguments) error {
 	var text [][]byte = [][]byte{{}}
-	_history := channel.History(ably.HistoryWithDirection(ably.Forwards))
-	history, err := _history.Items(ctx)
+	var live chan *ably.Message
+	var seq client.Sequence
+	replayed := make(map[string]bool)
+
+	if args.At != "" {
+		at, err := client.ParsePoint(args.At)
+		if err != nil {
+			return err
+		}
+		text, err := client.TextAt(ctx, channel, at)
+		if err != nil {
+			return err
+		}
+		printText(text)
+		return nil
+	}
+
+	if args.Meta {
+		return printMeta(ctx, channel)
+	}
+
+	if args.Follow {
+		live = make(chan *ably.Message, 100)
+		unsub, err := channel.SubscribeAll(ctx, func(msg *ably.Message) {
+			live <- msg
+		})
+		if err != nil {
+			return err
+		}
+		defer unsub()
+	}
+
+	err := client.Replay(ctx, channel, func(msg *ably.Message) {
+		replayed[msg.ID] = true
+		text = client.Apply(msg, text)
+	})
 	if err != nil {
 		return err
 	}
 
-	ok := history.Next(ctx)
-	if !ok {
-		return errors.New("No file found in session")
+	if !args.Follow {
+		printText(text)
+		return nil
 	}
 
-	item := history.Item()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
 
-	if item.Name != "new" {
-		return errors.New("No file found in session")
+	last := joinText(text)
+	if args.Format == "json" {
+		printChange(&ably.Message{Name: "new"}, last)
+	} else {
+		printText(text)
 	}
 
-	text = handleCatMessage(text, item)
-
 	for {
-		ok = history.Next(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-live:
+			if !seq.Accept(msg) || replayed[msg.ID] {
+				continue
+			}
+			switch msg.Name {
+			case "new", "add", "delete":
+			default:
+				continue
+			}
 
-		if !ok {
-			break
-		}
+			text = client.Apply(msg, text)
+			current := joinText(text)
+			if current == last {
+				continue
+			}
 
-		item = history.Item()
-		text = handleCatMessage(text, item)
+			switch args.Format {
+			case "diff":
+				fmt.Print(unifiedDiff(strings.Split(last, "\n"), strings.Split(current, "\n")))
+			case "json":
+				printChange(msg, current)
+			default:
+				fmt.Print("\f")
+				printText(text)
+			}
+			last = current
+		}
 	}
+}
 
-	err = history.Err()
+func printMeta(ctx context.Context, channel *ably.RealtimeChannel) error {
+	meta := &client.Meta{}
+	err := client.Replay(ctx, channel, func(msg *ably.Message) {
+		if m := client.MetaOf(msg); m != nil {
+			meta = m
+		}
+	})
 	if err != nil {
 		return err
 	}
+	js, _ := json.MarshalIndent(meta, "", "  ")
+	fmt.Println(string(js))
+	return nil
+}
 
+func joinText(text [][]byte) string {
+	return string(bytes.Join(text, []byte{'\n'}))
+}
+
+func printText(text [][]byte) {
 	for _, line := range text {
 		fmt.Println(string(line))
 	}
-
-	return nil
 }
 
-func handleCatMessage(text [][]byte, msg *ably.Message) [][]byte {
-	switch msg.Name {
-	case "new":
-		msg := msg.Data.(string)
-		text = applyNew(msg, text)
-	case "add":
-		var add Add
-		data := msg.Data.(string)
-		err := json.Unmarshal([]byte(data), &add)
-		if err != nil {
-			break
-		}
-		text = applyAdd(add, text)
-	case "delete":
-		var del Delete
-		data := msg.Data.(string)
-		err := json.Unmarshal([]byte(data), &del)
-		if err != nil {
-			break
+func printChange(msg *ably.Message, text string) {
+	change := catChange{Id: msg.ID, ClientId: msg.ClientID, Name: msg.Name, Timestamp: msg.Timestamp, Text: text}
+	switch data := msg.Data.(type) {
+	case string:
+		if msg.Name != "new" {
+			change.Op = json.RawMessage(data)
 		}
-		text = applyDel(del, text)
+	case []byte:
+		change.Op = json.RawMessage(data)
 	}
-	return text
+	js, _ := json.Marshal(change)
+	fmt.Println(string(js))
 }
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chown gives name the same owner as the file described by info, if it can.
+func chown(name string, info fs.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok {
+		os.Chown(name, int(stat.Uid), int(stat.Gid))
+	}
+}
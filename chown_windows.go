@@ -0,0 +1,5 @@
+package main
+
+import "io/fs"
+
+func chown(name string, info fs.FileInfo) {}
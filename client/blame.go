@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// The Blame functions keep track of the ClientID of the member who last
+// changed each line of a document. They take the text as it was before the op
+// is applied, and return the authors as they are after it.
+
+func BlameNew(s string, id string) []string {
+	authors := make([]string, bytes.Count([]byte(s), []byte{'\n'})+1)
+	for i := range authors {
+		authors[i] = id
+	}
+	return authors
+}
+
+func BlameAdd(add Add, id string, text [][]byte, authors []string) []string {
+	if !add.Valid(text) {
+		return authors
+	}
+	authors = fitAuthors(authors, len(text))
+
+	if add.Text == "" {
+		authors = append(authors[:add.Line+1], authors[add.Line:]...)
+		authors[add.Line+1] = id
+	} else {
+		authors[add.Line] = id
+	}
+	return authors
+}
+
+func BlameDel(del Delete, id string, text [][]byte, authors []string) []string {
+	if !del.Valid(text) {
+		return authors
+	}
+	authors = fitAuthors(authors, len(text))
+
+	if del.Count == 0 {
+		authors = append(authors[:del.Line+1], authors[del.Line+2:]...)
+	}
+	authors[del.Line] = id
+	return authors
+}
+
+// Blame updates authors for a "new", "add" or "delete" message.
+func Blame(msg *ably.Message, text [][]byte, authors []string) []string {
+	switch msg.Name {
+	case "new":
+		authors = NewAuthors(msg)
+	case "add":
+		var add Add
+		err := Decode(msg, &add)
+		if err != nil {
+			break
+		}
+		authors = BlameAdd(add, msg.ClientID, text, authors)
+	case "delete":
+		var del Delete
+		err := Decode(msg, &del)
+		if err != nil {
+			break
+		}
+		authors = BlameDel(del, msg.ClientID, text, authors)
+	}
+	return authors
+}
+
+// fitAuthors pads or truncates authors to n lines, in case they have got out
+// of step with the text.
+func fitAuthors(authors []string, n int) []string {
+	for len(authors) < n {
+		authors = append(authors, "")
+	}
+	return authors[:n]
+}
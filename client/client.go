@@ -0,0 +1,187 @@
+// Package client is a headless sync-edit participant, for bots and other
+// tools that join a session without the terminal UI.
+//
+// Like the editor, a Client only changes its text when messages come back from
+// ably, so edits made with Insert and Delete show up in Text once they have
+// been echoed on the channel and the change callback passed to Join has been
+// called.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// ChannelName returns the ably channel used by the session with the given code.
+func ChannelName(code string) string {
+	return "sync-edit:" + code
+}
+
+type Client struct {
+	Id      string
+	Code    string
+	Channel *ably.RealtimeChannel
+
+	mux      sync.Mutex
+	onChange func(msg *ably.Message)
+	text     [][]byte
+	replayed map[string]bool
+	seq      Sequence
+	entered  bool
+	unsub    func()
+}
+
+// Join attaches to an existing session and replays its history. onChange, if
+// not nil, is called after each live message has been applied to the text,
+// including any that arrive while the history is being replayed.
+func Join(ctx context.Context, realtime *ably.Realtime, code string, onChange func(msg *ably.Message)) (*Client, error) {
+	c := &Client{
+		Id:       realtime.Auth.ClientID(),
+		Code:     code,
+		Channel:  realtime.Channels.Get(ChannelName(code)),
+		onChange: onChange,
+		text:     [][]byte{{}},
+		replayed: make(map[string]bool),
+	}
+
+	err := c.Channel.Attach(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	presense, err := c.Channel.Presence.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(presense) == 0 {
+		return nil, fmt.Errorf("session '%s' does not exist", code)
+	}
+
+	// Hold the lock until the history is replayed so live messages are
+	// applied after it.
+	c.mux.Lock()
+	c.unsub, err = c.Channel.SubscribeAll(ctx, c.handleMessage)
+	if err != nil {
+		c.mux.Unlock()
+		return nil, err
+	}
+
+	err = Replay(ctx, c.Channel, func(msg *ably.Message) {
+		c.replayed[msg.ID] = true
+		c.text = Apply(msg, c.text)
+	})
+	c.mux.Unlock()
+	if err != nil {
+		c.unsub()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) handleMessage(msg *ably.Message) {
+	c.mux.Lock()
+	if !c.seq.Accept(msg) || c.replayed[msg.ID] {
+		c.mux.Unlock()
+		return
+	}
+	c.text = Apply(msg, c.text)
+	c.mux.Unlock()
+
+	if c.onChange != nil {
+		c.onChange(msg)
+	}
+}
+
+// Text returns the current document.
+func (c *Client) Text() string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return string(bytes.Join(c.text, []byte{'\n'}))
+}
+
+// Lines returns the current document split into lines.
+func (c *Client) Lines() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	lines := make([]string, len(c.text))
+	for i, line := range c.text {
+		lines[i] = string(line)
+	}
+	return lines
+}
+
+// Insert publishes the ops to insert s at pos on line. Newlines in s split the
+// line.
+func (c *Client) Insert(ctx context.Context, line, pos int, s string) error {
+	var msgs []*ably.Message
+	for i, part := range strings.Split(s, "\n") {
+		if i > 0 {
+			msgs = append(msgs, opMessage("add", &Add{Line: line, Pos: pos}))
+			line, pos = line+1, 0
+		}
+		if part != "" {
+			msgs = append(msgs, opMessage("add", &Add{Line: line, Pos: pos, Text: part}))
+			pos += len(part)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return c.Channel.PublishMultiple(ctx, msgs)
+}
+
+// Delete publishes an op removing count bytes from line at pos. A count of
+// zero joins line with the next one.
+func (c *Client) Delete(ctx context.Context, line, pos, count int) error {
+	return c.Channel.PublishMultiple(ctx, []*ably.Message{opMessage("delete", &Delete{Line: line, Pos: pos, Count: count})})
+}
+
+// SetCursor broadcasts the client's cursor position to the other members.
+func (c *Client) SetCursor(ctx context.Context, x, y int) error {
+	return c.Channel.PublishMultiple(ctx, []*ably.Message{opMessage("cursor", &Cursor{X: x, Y: y})})
+}
+
+// SetName enters presence as a bot with the given display name, or updates it
+// if the client is already present.
+func (c *Client) SetName(ctx context.Context, name string) error {
+	return c.SetPresence(ctx, Presence{Name: name, Role: "bot", Client: "sync-edit client"})
+}
+
+// SetPresence enters presence with p, or updates it if the client is already
+// present.
+func (c *Client) SetPresence(ctx context.Context, p Presence) error {
+	c.mux.Lock()
+	entered := c.entered
+	c.entered = true
+	c.mux.Unlock()
+
+	if entered {
+		return c.Channel.Presence.Update(ctx, p.Data())
+	}
+	return c.Channel.Presence.Enter(ctx, p.Data())
+}
+
+// Close leaves presence and stops applying messages.
+func (c *Client) Close(ctx context.Context) error {
+	c.unsub()
+	c.mux.Lock()
+	entered := c.entered
+	c.mux.Unlock()
+	if entered {
+		return c.Channel.Presence.Leave(ctx, nil)
+	}
+	return nil
+}
+
+func opMessage(name string, op interface{}) *ably.Message {
+	// work around ably bug
+	js, _ := json.Marshal(op)
+	return &ably.Message{Name: name, Data: js}
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+)
+
+var ErrNoFile = errors.New("No file found in session")
+
+// Point is a position in a session's history, either a time or the ID of a
+// message. The zero Point is the latest state of the session.
+type Point struct {
+	Time time.Time
+	Id   string
+}
+
+var pointLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "15:04:05", "15:04"}
+
+// ParsePoint parses a time, a unix time in milliseconds or a message ID. Times
+// without a date are taken to be today in the local time zone. Message IDs
+// are a connection ID followed by a serial and an index, separated by colons,
+// so anything else is a mistyped time.
+func ParsePoint(s string) (Point, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range pointLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			now := time.Now()
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+		}
+		return Point{Time: t}, nil
+	}
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return Point{Time: time.UnixMilli(ms)}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 || parts[0] == "" || !isNumber(parts[len(parts)-2]) || !isNumber(parts[len(parts)-1]) {
+		return Point{}, errors.New("Not a time or message ID: " + s)
+	}
+	return Point{Id: s}, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+func (p Point) String() string {
+	if p.Id != "" {
+		return p.Id
+	}
+	if !p.Time.IsZero() {
+		return p.Time.Format("2006-01-02 15:04:05")
+	}
+	return "now"
+}
+
+// Replay calls handle for the messages in the channel's history needed to
+// rebuild the document, oldest first. The first message is always a "new":
+// either the one that created the session, or the latest snapshot turned into
+// one. Changes made before that are skipped, so use ReplayAll to see who made
+// each change.
+func Replay(ctx context.Context, channel *ably.RealtimeChannel, handle func(*ably.Message)) error {
+	return ReplayUntil(ctx, channel, Point{}, handle)
+}
+
+// ReplayAll calls handle for every message in the channel's history, oldest
+// first, starting from the "new" that created the session. Unlike Replay it
+// doesn't skip to the latest snapshot, so that callers who attribute changes
+// to members see all of them. It returns ErrNoFile if that "new" is no longer
+// in the history.
+func ReplayAll(ctx context.Context, channel *ably.RealtimeChannel, handle func(*ably.Message)) error {
+	_history := channel.History(ably.HistoryWithDirection(ably.Forwards))
+	history, err := _history.Items(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = walkForward(func() *ably.Message {
+		if history.Next(ctx) {
+			return history.Item()
+		}
+		return nil
+	}, handle)
+	if history.Err() != nil {
+		return history.Err()
+	}
+	return err
+}
+
+// walkForward calls handle for the messages from the history, oldest first,
+// from the "new" that created the session. Only the "reset-result" published
+// with that "new" can come before it, and any "new" that doesn't replace the
+// document is skipped.
+func walkForward(newer func() *ably.Message, handle func(*ably.Message)) error {
+	var seq Sequence
+	started := false
+	for i := 0; ; i++ {
+		item := newer()
+		if item == nil {
+			break
+		}
+		if !seq.Accept(item) {
+			continue
+		}
+		if !started && item.Name != "new" {
+			if i > 0 {
+				return ErrNoFile
+			}
+			continue
+		}
+		started = true
+		handle(item)
+	}
+	if !started {
+		return ErrNoFile
+	}
+	return nil
+}
+
+// ReplayUntil is like Replay but stops at the given point in the history.
+func ReplayUntil(ctx context.Context, channel *ably.RealtimeChannel, at Point, handle func(*ably.Message)) error {
+	options := []ably.HistoryOption{ably.HistoryWithDirection(ably.Backwards)}
+	if !at.Time.IsZero() {
+		options = append(options, ably.HistoryWithEnd(at.Time))
+	}
+
+	_history := channel.History(options...)
+	history, err := _history.Items(ctx)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := walkBack(func() *ably.Message {
+		if history.Next(ctx) {
+			return history.Item()
+		}
+		return nil
+	}, at)
+	if history.Err() != nil {
+		return history.Err()
+	}
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		handle(msg)
+	}
+	return nil
+}
+
+// walkBack takes messages from the history, newest first, until the "new"
+// that started the document as it was at the given point, or the message the
+// latest snapshot before then was taken after. It returns the messages to
+// replay, oldest first, starting with that "new" or the snapshot turned into
+// one. A "new" is only known to replace the document once the message before
+// it has been seen, and any that doesn't is skipped.
+func walkBack(older func() *ably.Message, at Point) ([]*ably.Message, error) {
+	var msgs []*ably.Message
+	var snapshot *ably.Message
+	var snap Snapshot
+	var replaced *ably.Message
+	found := at.Id == ""
+	for item := older(); item != nil; item = older() {
+		if !found {
+			if item.ID != at.Id {
+				continue
+			}
+			found = true
+		}
+
+		if replaced != nil && Replaces(item, replaced) {
+			snapshot = nil
+			msgs = append(msgs, replaced)
+			break
+		}
+		replaced = nil
+		if snapshot != nil && item.ID == snap.After {
+			break
+		}
+		if item.Name == "new" {
+			replaced = item
+			continue
+		}
+		if item.Name == "snapshot" && snapshot == nil && Decode(item, &snap) == nil && snap.After != "" {
+			snapshot = item
+			continue
+		}
+		msgs = append(msgs, item)
+	}
+	if !found {
+		return nil, errors.New("message " + at.Id + " not found in session")
+	}
+
+	var replay []*ably.Message
+	if snapshot != nil {
+		replay = append(replay, snap.Message(snapshot))
+	} else if len(msgs) == 0 || msgs[len(msgs)-1].Name != "new" {
+		return nil, ErrNoFile
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		replay = append(replay, msgs[i])
+	}
+	return replay, nil
+}
+
+// TextAt reconstructs the document as it was at the given point.
+func TextAt(ctx context.Context, channel *ably.RealtimeChannel, at Point) ([][]byte, error) {
+	text := [][]byte{{}}
+	err := ReplayUntil(ctx, channel, at, func(msg *ably.Message) {
+		text = Apply(msg, text)
+	})
+	return text, err
+}
+
+// PreviousText reconstructs the document as it was just before the latest
+// "new" message replaced it.
+func PreviousText(ctx context.Context, channel *ably.RealtimeChannel) ([][]byte, error) {
+	_history := channel.History(ably.HistoryWithDirection(ably.Backwards))
+	history, err := _history.Items(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var replaced *ably.Message
+	for history.Next(ctx) {
+		item := history.Item()
+		if replaced != nil && Replaces(item, replaced) {
+			return TextAt(ctx, channel, Point{Id: item.ID})
+		}
+		replaced = nil
+		if item.Name == "new" {
+			replaced = item
+		}
+	}
+	if history.Err() != nil {
+		return nil, history.Err()
+	}
+	return nil, errors.New("There is no previous document")
+}
@@ -0,0 +1,321 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ably/ably-go/ably"
+)
+
+func TestParsePoint(t *testing.T) {
+	now := time.Now()
+	today := func(hour, min, sec int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), hour, min, sec, 0, time.Local)
+	}
+	tests := []struct {
+		name string
+		in   string
+		want Point
+		err  bool
+	}{
+		{"rfc3339", "2024-03-01T10:20:30Z", Point{Time: time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)}, false},
+		{"date and time", "2024-03-01 10:20:30", Point{Time: time.Date(2024, 3, 1, 10, 20, 30, 0, time.Local)}, false},
+		{"date and minutes", "2024-03-01 10:20", Point{Time: time.Date(2024, 3, 1, 10, 20, 0, 0, time.Local)}, false},
+		{"time today", "10:20:30", Point{Time: today(10, 20, 30)}, false},
+		{"minutes today", "10:20", Point{Time: today(10, 20, 0)}, false},
+		{"unix milliseconds", "1709288430000", Point{Time: time.UnixMilli(1709288430000)}, false},
+		{"message id", "Kx2pQ9vZ:12:0", Point{Id: "Kx2pQ9vZ:12:0"}, false},
+		{"surrounding space", " 10:20 ", Point{Time: today(10, 20, 0)}, false},
+		{"empty", "", Point{}, true},
+		{"word", "yesterday", Point{}, true},
+		{"bad date", "2024-13-01 10:20", Point{}, true},
+		{"bad time", "25:61", Point{}, true},
+		{"id without serial", "Kx2pQ9vZ:x:0", Point{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParsePoint(test.in)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Id != test.want.Id || !got.Time.Equal(test.want.Time) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// session builds a history from messages, oldest first, giving each an ID.
+// Messages are from the host unless they say otherwise.
+func session(msgs ...*ably.Message) []*ably.Message {
+	for i, msg := range msgs {
+		msg.ID = fmt.Sprintf("conn:%d:0", i)
+		if msg.ClientID == "" {
+			msg.ClientID = "host"
+		}
+	}
+	return msgs
+}
+
+func result(approved bool) *ably.Message {
+	js, _ := json.Marshal(ResetResult{Approved: approved})
+	return &ably.Message{Name: "reset-result", Data: js}
+}
+
+func newText(text string) *ably.Message {
+	return &ably.Message{Name: "new", Data: text}
+}
+
+func add(line, pos int, text string) *ably.Message {
+	js, _ := json.Marshal(Add{Line: line, Pos: pos, Text: text})
+	return &ably.Message{Name: "add", Data: js}
+}
+
+func snapshot(after int, text string) *ably.Message {
+	s := MakeSnapshot(fmt.Sprintf("conn:%d:0", after), ApplyNew(text, nil), nil, &Meta{File: "a.txt"})
+	return &ably.Message{Name: "snapshot", Data: s.Data()}
+}
+
+// from returns msg as sent by the given client.
+func from(id string, msg *ably.Message) *ably.Message {
+	msg.ClientID = id
+	return msg
+}
+
+// iterate returns the history one message at a time, newest first if
+// backwards is set.
+func iterate(history []*ably.Message, backwards bool) func() *ably.Message {
+	i := 0
+	return func() *ably.Message {
+		if i == len(history) {
+			return nil
+		}
+		i++
+		if backwards {
+			return history[len(history)-i]
+		}
+		return history[i-1]
+	}
+}
+
+func replayText(msgs []*ably.Message) string {
+	text := [][]byte{{}}
+	for _, msg := range msgs {
+		text = Apply(msg, text)
+	}
+	return join(text)
+}
+
+func TestReplaces(t *testing.T) {
+	tests := []struct {
+		name string
+		prev *ably.Message
+		want bool
+	}{
+		{"approved", from("host", result(true)), true},
+		{"first message", nil, false},
+		{"declined", from("host", result(false)), false},
+		{"someone else's result", from("member", result(true)), false},
+		{"not a result", from("host", add(0, 0, "a")), false},
+		{"bad result", &ably.Message{Name: "reset-result", ClientID: "host", Data: "{"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Replaces(test.prev, from("host", newText("x"))); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWalkBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []*ably.Message
+		at      Point
+		want    string
+		first   string
+		err     error
+	}{
+		{
+			"from the new",
+			session(result(true), newText("ab"), add(0, 2, "c"), add(0, 3, "d")),
+			Point{}, "abcd", "conn:1:0", nil,
+		},
+		{
+			"latest new",
+			session(result(true), newText("ab"), add(0, 2, "c"), result(true), newText("xy"), add(0, 2, "z")),
+			Point{}, "xyz", "conn:4:0", nil,
+		},
+		{
+			"new nobody agreed to",
+			session(result(true), newText("ab"), add(0, 2, "c"), newText("xy"), add(0, 3, "d")),
+			Point{}, "abcd", "conn:1:0", nil,
+		},
+		{
+			"new after a declined result",
+			session(result(true), newText("ab"), result(false), newText("xy")),
+			Point{}, "ab", "conn:1:0", nil,
+		},
+		{
+			"new after someone else's result",
+			session(result(true), newText("ab"), result(true), from("member", newText("xy"))),
+			Point{}, "ab", "conn:1:0", nil,
+		},
+		{
+			"from the snapshot",
+			session(result(true), newText("ab"), add(0, 2, "c"), add(0, 3, "d"), snapshot(3, "abcd"), add(0, 4, "e")),
+			Point{}, "abcde", "conn:4:0", nil,
+		},
+		{
+			"ops published before the snapshot after what it covers",
+			session(result(true), newText("ab"), add(0, 2, "c"), add(0, 3, "d"), snapshot(2, "abc"), add(0, 4, "e")),
+			Point{}, "abcde", "conn:4:0", nil,
+		},
+		{
+			"latest snapshot",
+			session(result(true), newText("a"), snapshot(1, "a"), add(0, 1, "b"), snapshot(3, "ab"), add(0, 2, "c")),
+			Point{}, "abc", "conn:4:0", nil,
+		},
+		{
+			"new after the snapshot",
+			session(result(true), newText("a"), snapshot(1, "a"), result(true), newText("x"), add(0, 1, "y")),
+			Point{}, "xy", "conn:4:0", nil,
+		},
+		{
+			"new nobody agreed to after the snapshot",
+			session(result(true), newText("a"), snapshot(1, "a"), newText("x"), add(0, 1, "y")),
+			Point{}, "ay", "conn:2:0", nil,
+		},
+		{
+			"snapshot taken between the result and the new",
+			session(result(true), newText("a"), result(true), newText("x"), snapshot(2, "a")),
+			Point{}, "x", "conn:3:0", nil,
+		},
+		{
+			"at a message before the snapshot",
+			session(result(true), newText("ab"), add(0, 2, "c"), add(0, 3, "d"), snapshot(3, "abcd"), add(0, 4, "e")),
+			Point{Id: "conn:2:0"}, "abc", "conn:1:0", nil,
+		},
+		{
+			"at a message after the snapshot",
+			session(result(true), newText("ab"), add(0, 2, "c"), snapshot(2, "abc"), add(0, 3, "d"), add(0, 4, "e")),
+			Point{Id: "conn:4:0"}, "abcd", "conn:3:0", nil,
+		},
+		{
+			"at the result before a new",
+			session(result(true), newText("ab"), result(true), newText("xy")),
+			Point{Id: "conn:2:0"}, "ab", "conn:1:0", nil,
+		},
+		{
+			"new without a result",
+			session(newText("ab"), add(0, 2, "c")),
+			Point{}, "", "", ErrNoFile,
+		},
+		{
+			"no new",
+			session(add(0, 0, "a"), add(0, 1, "b")),
+			Point{}, "", "", ErrNoFile,
+		},
+		{
+			"empty",
+			nil,
+			Point{}, "", "", ErrNoFile,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msgs, err := walkBack(iterate(test.history, true), test.at)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if msgs[0].Name != "new" || msgs[0].ID != test.first {
+				t.Errorf("replay starts with %s %s, want new %s", msgs[0].Name, msgs[0].ID, test.first)
+			}
+			if got := replayText(msgs); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWalkBackMissingPoint(t *testing.T) {
+	history := session(result(true), newText("a"), add(0, 1, "b"))
+	_, err := walkBack(iterate(history, true), Point{Id: "other:1:0"})
+	if err == nil || err == ErrNoFile {
+		t.Errorf("got %v, want an error saying the message wasn't found", err)
+	}
+}
+
+func TestSnapshotKeepsMeta(t *testing.T) {
+	history := session(result(true), newText("a"), snapshot(1, "a"))
+	msgs, err := walkBack(iterate(history, true), Point{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := MetaOf(msgs[0]); meta == nil || meta.File != "a.txt" {
+		t.Errorf("got meta %+v, want the snapshot's", meta)
+	}
+	if msgs[0].ClientID != "host" {
+		t.Errorf("got client %q, want the snapshot's publisher", msgs[0].ClientID)
+	}
+}
+
+func TestWalkForward(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []*ably.Message
+		want    string
+		handled int
+		err     error
+	}{
+		{"from the new", session(result(true), newText("ab"), add(0, 2, "c")), "abc", 2, nil},
+		{"every change", session(result(true), newText("ab"), snapshot(1, "ab"), result(true), newText("x"), add(0, 1, "y")), "xy", 5, nil},
+		{"new nobody agreed to", session(result(true), newText("ab"), newText("x"), add(0, 2, "c")), "abc", 2, nil},
+		{"new without a result", session(newText("ab"), add(0, 2, "c")), "", 0, ErrNoFile},
+		{"started before the history", session(add(0, 0, "a"), result(true), newText("x")), "", 0, ErrNoFile},
+		{"empty", nil, "", 0, ErrNoFile},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var msgs []*ably.Message
+			err := walkForward(iterate(test.history, false), func(msg *ably.Message) {
+				msgs = append(msgs, msg)
+			})
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(msgs) != test.handled {
+				t.Errorf("handled %d messages, want %d", len(msgs), test.handled)
+			}
+			if got := replayText(msgs); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSequence(t *testing.T) {
+	var seq Sequence
+	msgs := session(result(true), newText("a"), add(0, 1, "b"), newText("x"), result(true), newText("y"))
+	want := []bool{true, true, true, false, true, true}
+	for i, msg := range msgs {
+		if got := seq.Accept(msg); got != want[i] {
+			t.Errorf("message %d %s: got %v, want %v", i, msg.Name, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// Meta describes the document being edited. The host sends it in a "meta"
+// message when the session is created and whenever it changes, and it is
+// carried in snapshots so replaying the history always finds it.
+type Meta struct {
+	File       string `json:"file,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Indent     string `json:"indent,omitempty"`
+	TabWidth   int    `json:"tabWidth,omitempty"`
+	LineEnding string `json:"lineEnding,omitempty"`
+}
+
+var languages = map[string]string{
+	".c":    "c",
+	".css":  "css",
+	".go":   "go",
+	".h":    "c",
+	".html": "html",
+	".java": "java",
+	".js":   "javascript",
+	".json": "json",
+	".md":   "markdown",
+	".py":   "python",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".sh":   "shell",
+	".ts":   "typescript",
+	".txt":  "text",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
+
+// aliases maps the names interpreters, vim filetypes and emacs modes use for
+// a language to the one in languages.
+var aliases = map[string]string{
+	"bash":         "shell",
+	"dash":         "shell",
+	"js":           "javascript",
+	"ksh":          "shell",
+	"node":         "javascript",
+	"nodejs":       "javascript",
+	"py":           "python",
+	"sh":           "shell",
+	"shell-script": "shell",
+	"zsh":          "shell",
+}
+
+// Language guesses the language of a file from its extension.
+func Language(file string) string {
+	return languages[strings.ToLower(filepath.Ext(file))]
+}
+
+// languageNamed returns the language called name by an interpreter, vim or
+// emacs, ignoring any version on the end as in "python3".
+func languageNamed(name string) string {
+	name = strings.ToLower(name)
+	if language, ok := aliases[name]; ok {
+		return language
+	}
+	if trimmed := strings.TrimRight(name, "0123456789."); trimmed != "" && trimmed != name {
+		if language, ok := aliases[trimmed]; ok {
+			return language
+		}
+		name = trimmed
+	}
+	return name
+}
+
+// shebang returns the language of the interpreter named on a "#!" line, or ""
+// if the line isn't one.
+func shebang(line []byte) string {
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return ""
+	}
+	fields := strings.Fields(string(line[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	if interpreter == "" {
+		return ""
+	}
+	return languageNamed(interpreter)
+}
+
+// modelineLines is how many lines at each end of a file are searched for a
+// modeline, as vim does.
+const modelineLines = 5
+
+// modeline applies the settings in a vim modeline, such as
+// "vim: set ft=go ts=4 noet:", or an emacs one, such as
+// "-*- mode: python; indent-tabs-mode: nil; tab-width: 4 -*-", to meta.
+// It reports whether line had one.
+func (m *Meta) modeline(line string) bool {
+	if start := strings.Index(line, "-*-"); start >= 0 {
+		if end := strings.Index(line[start+3:], "-*-"); end >= 0 {
+			m.emacs(line[start+3 : start+3+end])
+			return true
+		}
+	}
+	for _, marker := range []string{"vim:", "vi:"} {
+		at := strings.Index(line, marker)
+		if at < 0 || at > 0 && line[at-1] != ' ' && line[at-1] != '\t' {
+			continue
+		}
+		m.vim(line[at+len(marker):])
+		return true
+	}
+	return false
+}
+
+func (m *Meta) vim(options string) {
+	options = strings.TrimSpace(options)
+	if strings.HasPrefix(options, "set ") || strings.HasPrefix(options, "se ") {
+		options = options[strings.Index(options, " ")+1:]
+		if end := strings.Index(options, ":"); end >= 0 {
+			options = options[:end]
+		}
+	} else {
+		options = strings.ReplaceAll(options, ":", " ")
+	}
+	shiftwidth := 0
+	for _, option := range strings.Fields(options) {
+		name, value, _ := strings.Cut(option, "=")
+		switch name {
+		case "ft", "filetype", "syn", "syntax":
+			if value != "" {
+				m.Language = languageNamed(value)
+			}
+		case "ts", "tabstop":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				m.TabWidth = n
+			}
+		case "sw", "shiftwidth":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				shiftwidth = n
+			}
+		case "et", "expandtab":
+			m.Indent = "spaces"
+		case "noet", "noexpandtab":
+			m.Indent = "tabs"
+		}
+	}
+	if shiftwidth > 0 {
+		m.TabWidth = shiftwidth
+	}
+}
+
+func (m *Meta) emacs(variables string) {
+	if !strings.Contains(variables, ":") {
+		if mode := strings.TrimSpace(variables); mode != "" {
+			m.Language = languageNamed(mode)
+		}
+		return
+	}
+	for _, variable := range strings.Split(variables, ";") {
+		name, value, ok := strings.Cut(variable, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "mode":
+			if value != "" {
+				m.Language = languageNamed(value)
+			}
+		case "tab-width":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				m.TabWidth = n
+			}
+		case "indent-tabs-mode":
+			if value == "nil" {
+				m.Indent = "spaces"
+			} else {
+				m.Indent = "tabs"
+			}
+		}
+	}
+}
+
+// DetectMeta works out the settings of a document from its file name and
+// contents. The language comes from the extension, or else a "#!" line.
+// Indentation is "tabs" or "spaces", going by whichever more lines start with,
+// and TabWidth is the smallest space indent seen. A vim or emacs modeline in
+// the first or last few lines overrides any of these.
+func DetectMeta(file string, text []byte) Meta {
+	meta := Meta{Language: Language(file), Indent: "spaces", TabWidth: 4, LineEnding: "lf"}
+	if file != "" {
+		meta.File = filepath.Base(file)
+	}
+	lines := bytes.Split(text, []byte{'\n'})
+	if meta.Language == "" {
+		meta.Language = shebang(bytes.TrimSuffix(lines[0], []byte{'\r'}))
+	}
+	if bytes.Contains(text, []byte("\r\n")) {
+		meta.LineEnding = "crlf"
+	}
+
+	tabs, spaces, width := 0, 0, 0
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '\t' {
+			tabs++
+		} else if n := len(line) - len(bytes.TrimLeft(line, " ")); n > 0 && n < len(line) {
+			spaces++
+			if width == 0 || n < width {
+				width = n
+			}
+		}
+	}
+	if tabs > spaces {
+		meta.Indent = "tabs"
+	} else if width > 1 && width <= 8 {
+		meta.TabWidth = width
+	}
+
+	for i, line := range lines {
+		if i >= modelineLines && i < len(lines)-modelineLines {
+			continue
+		}
+		if meta.modeline(string(bytes.TrimSuffix(line, []byte{'\r'}))) {
+			break
+		}
+	}
+	return meta
+}
+
+// IndentText returns what pressing tab inserts.
+func (m Meta) IndentText() string {
+	if m.Indent == "tabs" {
+		return "\t"
+	}
+	width := m.TabWidth
+	if width <= 0 {
+		width = 4
+	}
+	return strings.Repeat(" ", width)
+}
+
+// Join returns text with the lines ending the way the document's do.
+func (m Meta) Join(text [][]byte) []byte {
+	if m.LineEnding != "crlf" {
+		return bytes.Join(text, []byte{'\n'})
+	}
+	lines := make([][]byte, len(text))
+	for i, line := range text {
+		lines[i] = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+	return bytes.Join(lines, []byte("\r\n"))
+}
+
+func (m *Meta) Data() []byte {
+	js, _ := json.Marshal(m)
+	return js
+}
+
+// MetaOf returns the metadata carried by a "meta" message, or by the "new"
+// message a snapshot is replayed as, and nil for anything else.
+func MetaOf(msg *ably.Message) *Meta {
+	switch msg.Name {
+	case "meta":
+		var meta Meta
+		if Decode(msg, &meta) == nil {
+			return &meta
+		}
+	case "new":
+		meta, _ := msg.Extras["meta"].(*Meta)
+		return meta
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package client
+
+import "testing"
+
+func TestDetectMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		text string
+		want Meta
+	}{
+		{"extension", "main.go", "package main\n", Meta{File: "main.go", Language: "go", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"upper case extension", "README.MD", "# Title\n", Meta{File: "README.MD", Language: "markdown", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"unknown extension", "notes.xyz", "notes\n", Meta{File: "notes.xyz", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"no file", "", "text\n", Meta{Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"tabs", "main.go", "func main() {\n\tx()\n\ty()\n}\n", Meta{File: "main.go", Language: "go", Indent: "tabs", TabWidth: 4, LineEnding: "lf"}},
+		{"space width", "a.py", "if x:\n  y()\n  if z:\n    w()\n", Meta{File: "a.py", Language: "python", Indent: "spaces", TabWidth: 2, LineEnding: "lf"}},
+		{"crlf", "a.txt", "one\r\ntwo\r\n", Meta{File: "a.txt", Language: "text", Indent: "spaces", TabWidth: 4, LineEnding: "crlf"}},
+		{"shebang", "build", "#!/bin/sh\necho hi\n", Meta{File: "build", Language: "shell", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"shebang with env", "tool", "#!/usr/bin/env python3\nprint()\n", Meta{File: "tool", Language: "python", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"shebang with env flags", "tool", "#!/usr/bin/env -S node --harmony\n", Meta{File: "tool", Language: "javascript", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"shebang with crlf", "tool", "#!/usr/bin/ruby\r\nputs 1\r\n", Meta{File: "tool", Language: "ruby", Indent: "spaces", TabWidth: 4, LineEnding: "crlf"}},
+		{"extension beats shebang", "run.py", "#!/bin/bash\n", Meta{File: "run.py", Language: "python", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{"not a shebang", "tool", "# !/bin/sh\n", Meta{File: "tool", Indent: "spaces", TabWidth: 4, LineEnding: "lf"}},
+		{
+			"vim modeline",
+			"notes",
+			"text\n\n# vim: set ft=python ts=8 sw=2 et:\n",
+			Meta{File: "notes", Language: "python", Indent: "spaces", TabWidth: 2, LineEnding: "lf"},
+		},
+		{
+			"vim modeline without set",
+			"notes",
+			"// vim: filetype=go:tabstop=8:noexpandtab\ntext\n",
+			Meta{File: "notes", Language: "go", Indent: "tabs", TabWidth: 8, LineEnding: "lf"},
+		},
+		{
+			"vi modeline",
+			"script",
+			"# vi: ft=bash\necho\n",
+			Meta{File: "script", Language: "shell", Indent: "spaces", TabWidth: 4, LineEnding: "lf"},
+		},
+		{
+			"modeline beats the extension and indentation",
+			"a.txt",
+			"x\n    y\n    z\n/* vim: set ft=c noet ts=4: */",
+			Meta{File: "a.txt", Language: "c", Indent: "tabs", TabWidth: 4, LineEnding: "lf"},
+		},
+		{
+			"modeline in the middle is ignored",
+			"a.txt",
+			"1\n2\n3\n4\n5\nvim: ft=go\n7\n8\n9\n10\n11\n",
+			Meta{File: "a.txt", Language: "text", Indent: "spaces", TabWidth: 4, LineEnding: "lf"},
+		},
+		{
+			"not a modeline",
+			"a.txt",
+			"navim: ft=go\n",
+			Meta{File: "a.txt", Language: "text", Indent: "spaces", TabWidth: 4, LineEnding: "lf"},
+		},
+		{
+			"emacs mode",
+			"Makefile.inc",
+			"# -*- mode: ruby; indent-tabs-mode: t; tab-width: 8 -*-\n",
+			Meta{File: "Makefile.inc", Language: "ruby", Indent: "tabs", TabWidth: 8, LineEnding: "lf"},
+		},
+		{
+			"emacs mode alone",
+			"conf",
+			"#!/usr/bin/env bash\n# -*- shell-script -*-\n\tx\n",
+			Meta{File: "conf", Language: "shell", Indent: "tabs", TabWidth: 4, LineEnding: "lf"},
+		},
+		{
+			"emacs spaces",
+			"a.c",
+			"/* -*- indent-tabs-mode: nil -*- */\n\tx\n",
+			Meta{File: "a.c", Language: "c", Indent: "spaces", TabWidth: 4, LineEnding: "lf"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := DetectMeta(test.file, []byte(test.text))
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
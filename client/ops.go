@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// Add inserts Text at Pos on Line. An empty Text splits the line at Pos.
+type Add struct {
+	Line int    `json:"line"`
+	Pos  int    `json:"pos"`
+	Text string `json:"text"`
+}
+
+// Delete removes Count bytes from Line starting at Pos. A Count of zero joins
+// Line with the line after it.
+type Delete struct {
+	Line  int `json:"line"`
+	Pos   int `json:"pos"`
+	Count int `json:"count"`
+}
+
+// Cursor is a member's cursor position in the document, and the origin of
+// their view of it so that others can follow them as they scroll. If Mark is
+// set, the text between MX, MY and the cursor is selected.
+type Cursor struct {
+	X    int  `json:"x"`
+	Y    int  `json:"y"`
+	OX   int  `json:"ox,omitempty"`
+	OY   int  `json:"oy,omitempty"`
+	Mark bool `json:"mark,omitempty"`
+	MX   int  `json:"mx,omitempty"`
+	MY   int  `json:"my,omitempty"`
+}
+
+// Handover is sent in a "host" message by the host to make another member
+// the host.
+type Handover struct {
+	Client string `json:"client"`
+}
+
+// Saved is sent in a "saved" message when the host saves the document, so
+// members know the file on disk is up to date. Hash is the hex SHA-256 of the
+// text that was saved, with the lines joined by "\n".
+type Saved struct {
+	File string `json:"file"`
+	Hash string `json:"hash,omitempty"`
+}
+
+// SaveRequest is sent in a "save-request" message by a member asking the host
+// to save the document.
+type SaveRequest struct{}
+
+// SaveResult is sent in a "save-result" message by the host in reply to a
+// SaveRequest from Client. Error is empty if the save succeeded.
+type SaveResult struct {
+	Client string `json:"client"`
+	File   string `json:"file,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ResetRequest is sent in a "reset-request" message by a member asking to
+// clear the document, with Restore set to bring back the one from before the
+// last reset, or by the host with Open set to replace it with the file of that
+// name. It goes ahead if the host allows it or most members agree.
+type ResetRequest struct {
+	Id      string `json:"id"`
+	Restore bool   `json:"restore,omitempty"`
+	Open    string `json:"open,omitempty"`
+}
+
+// ResetVote is sent in a "reset-vote" message by each member asked about a
+// ResetRequest.
+type ResetVote struct {
+	Id    string `json:"id"`
+	Agree bool   `json:"agree"`
+}
+
+// ResetResult is sent in a "reset-result" message by the host once a
+// ResetRequest has been decided. One that approves is also sent straight
+// before every "new", in the same batch, with an empty Id if nobody asked.
+type ResetResult struct {
+	Id       string `json:"id"`
+	Approved bool   `json:"approved"`
+}
+
+// Replaces reports whether msg, a "new", replaces the document, given prev,
+// the message before it in the channel. A "new" only counts straight after an
+// approving "reset-result" from the same client, so one that nobody agreed to
+// is ignored by everyone, whether they see it live or in the history.
+func Replaces(prev, msg *ably.Message) bool {
+	if prev == nil || prev.Name != "reset-result" || prev.ClientID != msg.ClientID {
+		return false
+	}
+	var result ResetResult
+	return Decode(prev, &result) == nil && result.Approved
+}
+
+// Sequence follows the messages on a channel as they arrive, to check each
+// "new" with Replaces. The zero Sequence is ready to use.
+type Sequence struct {
+	last *ably.Message
+}
+
+// Accept reports whether msg, the next message on the channel, should be
+// applied: anything but a "new" that doesn't replace the document.
+func (s *Sequence) Accept(msg *ably.Message) bool {
+	prev := s.last
+	s.last = msg
+	return msg.Name != "new" || Replaces(prev, msg)
+}
+
+// End is sent in an "end" message when the host ends the session.
+type End struct{}
+
+// Presentation is sent in a "present" message when a member starts or stops
+// presenting.
+type Presentation struct {
+	Active bool `json:"active"`
+}
+
+// Decode unmarshals the JSON data of msg into v. Ops are published as JSON
+// bytes but may come back as either a string or a byte slice.
+func Decode(msg *ably.Message, v interface{}) error {
+	switch data := msg.Data.(type) {
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case []byte:
+		return json.Unmarshal(data, v)
+	}
+	return errors.New("unexpected message data")
+}
+
+// Valid reports whether add can be applied to text.
+func (add Add) Valid(text [][]byte) bool {
+	return add.Line >= 0 && add.Line < len(text) && add.Pos >= 0 && add.Pos <= len(text[add.Line])
+}
+
+// Valid reports whether del can be applied to text.
+func (del Delete) Valid(text [][]byte) bool {
+	if del.Line < 0 || del.Line >= len(text) || del.Pos < 0 || del.Count < 0 {
+		return false
+	}
+	if del.Count == 0 {
+		return del.Line+1 < len(text)
+	}
+	return del.Count+del.Pos-1 < len(text[del.Line])
+}
+
+// ApplyAdd applies add to text, returning text unchanged if add isn't Valid.
+func ApplyAdd(add Add, text [][]byte) [][]byte {
+	if !add.Valid(text) {
+		return text
+	}
+
+	if add.Text == "" {
+		text = append(text[:add.Line+1], text[add.Line:]...)
+		text[add.Line+1] = text[add.Line][add.Pos:]
+		// Cap the first half so appending to it can't overwrite the second.
+		text[add.Line] = text[add.Line][:add.Pos:add.Pos]
+	} else if add.Pos == len(text[add.Line]) {
+		text[add.Line] = append(text[add.Line], []byte(add.Text)...)
+	} else {
+		end := append([]byte(nil), text[add.Line][add.Pos:]...)
+		text[add.Line] = append(text[add.Line][:add.Pos], []byte(add.Text)...)
+		text[add.Line] = append(text[add.Line], end...)
+	}
+
+	return text
+}
+
+// ApplyNew replaces text with s.
+func ApplyNew(s string, text [][]byte) [][]byte {
+	return bytes.Split([]byte(s), []byte{'\n'})
+}
+
+// ApplyDel applies del to text, returning text unchanged if del isn't Valid.
+// Joining the last line with the one after it is not Valid.
+func ApplyDel(del Delete, text [][]byte) [][]byte {
+	if !del.Valid(text) {
+		return text
+	}
+
+	if del.Count == 0 {
+		line := text[del.Line]
+		text = append(text[:del.Line], text[del.Line+1:]...)
+		text[del.Line] = append(line, text[del.Line]...)
+	} else {
+		text[del.Line] = append(text[del.Line][:del.Pos], text[del.Line][del.Pos+del.Count:]...)
+	}
+	return text
+}
+
+// Apply applies a "new", "add" or "delete" message to text. Any other message,
+// or one that fails to decode, leaves text unchanged.
+func Apply(msg *ably.Message, text [][]byte) [][]byte {
+	switch msg.Name {
+	case "new":
+		s, _ := msg.Data.(string)
+		text = ApplyNew(s, text)
+	case "add":
+		var add Add
+		err := Decode(msg, &add)
+		if err != nil {
+			break
+		}
+		text = ApplyAdd(add, text)
+	case "delete":
+		var del Delete
+		err := Decode(msg, &del)
+		if err != nil {
+			break
+		}
+		text = ApplyDel(del, text)
+	}
+	return text
+}
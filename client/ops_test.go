@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ably/ably-go/ably"
+)
+
+func join(text [][]byte) string {
+	return string(bytes.Join(text, []byte{'\n'}))
+}
+
+func TestApplyAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		add  Add
+		want string
+	}{
+		{"insert middle", "abc", Add{Line: 0, Pos: 1, Text: "X"}, "aXbc"},
+		{"insert start", "abc", Add{Line: 0, Pos: 0, Text: "X"}, "Xabc"},
+		{"insert end", "abc", Add{Line: 0, Pos: 3, Text: "X"}, "abcX"},
+		{"insert second line", "a\nb", Add{Line: 1, Pos: 1, Text: "X"}, "a\nbX"},
+		{"split middle", "abc", Add{Line: 0, Pos: 1}, "a\nbc"},
+		{"split start", "abc", Add{Line: 0, Pos: 0}, "\nabc"},
+		{"split end", "abc", Add{Line: 0, Pos: 3}, "abc\n"},
+		{"split empty", "", Add{Line: 0, Pos: 0}, "\n"},
+		{"line past end", "abc", Add{Line: 1, Pos: 0, Text: "X"}, "abc"},
+		{"negative line", "abc", Add{Line: -1, Pos: 0, Text: "X"}, "abc"},
+		{"pos past end", "abc", Add{Line: 0, Pos: 4, Text: "X"}, "abc"},
+		{"negative pos", "abc", Add{Line: 0, Pos: -1, Text: "X"}, "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := join(ApplyAdd(test.add, ApplyNew(test.text, nil)))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyDel(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		del  Delete
+		want string
+	}{
+		{"middle", "abcd", Delete{Line: 0, Pos: 1, Count: 2}, "ad"},
+		{"start", "abcd", Delete{Line: 0, Pos: 0, Count: 1}, "bcd"},
+		{"end", "abcd", Delete{Line: 0, Pos: 3, Count: 1}, "abc"},
+		{"whole line", "abcd\nx", Delete{Line: 0, Pos: 0, Count: 4}, "\nx"},
+		{"join", "ab\ncd\nef", Delete{Line: 0, Pos: 0, Count: 0}, "abcd\nef"},
+		{"join empty", "\n\nx", Delete{Line: 1, Pos: 0, Count: 0}, "\nx"},
+		{"join last line", "ab\ncd", Delete{Line: 1, Pos: 0, Count: 0}, "ab\ncd"},
+		{"count past end", "abcd", Delete{Line: 0, Pos: 2, Count: 3}, "abcd"},
+		{"pos past end", "abcd", Delete{Line: 0, Pos: 4, Count: 1}, "abcd"},
+		{"negative count", "abcd", Delete{Line: 0, Pos: 1, Count: -1}, "abcd"},
+		{"negative pos", "abcd", Delete{Line: 0, Pos: -1, Count: 1}, "abcd"},
+		{"line past end", "abcd", Delete{Line: 1, Pos: 0, Count: 1}, "abcd"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := join(ApplyDel(test.del, ApplyNew(test.text, nil)))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func message(t *testing.T, name string, v interface{}) *ably.Message {
+	if s, ok := v.(string); ok {
+		return &ably.Message{Name: name, Data: s}
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ably.Message{Name: name, Data: js}
+}
+
+func TestApply(t *testing.T) {
+	add := func(line, pos int, s string) Add { return Add{Line: line, Pos: pos, Text: s} }
+	tests := []struct {
+		name string
+		msgs []*ably.Message
+		want string
+	}{
+		{"new", []*ably.Message{message(t, "new", "a\nb")}, "a\nb"},
+		{"ops", []*ably.Message{
+			message(t, "new", "abc"),
+			message(t, "add", add(0, 1, "")),
+			message(t, "add", add(0, 1, "X")),
+			message(t, "delete", Delete{Line: 1, Pos: 0, Count: 1}),
+			message(t, "delete", Delete{Line: 0, Pos: 0, Count: 0}),
+		}, "aXc"},
+		{"string data", []*ably.Message{
+			message(t, "new", "abc"),
+			{Name: "add", Data: `{"line":0,"pos":3,"text":"d"}`},
+		}, "abcd"},
+		{"new resets", []*ably.Message{
+			message(t, "new", "abc"),
+			message(t, "add", add(0, 0, "X")),
+			message(t, "new", "z"),
+		}, "z"},
+		{"other names ignored", []*ably.Message{
+			message(t, "new", "abc"),
+			message(t, "cursor", Cursor{X: 1}),
+			message(t, "saved", Saved{File: "x"}),
+		}, "abc"},
+		{"bad data ignored", []*ably.Message{
+			message(t, "new", "abc"),
+			{Name: "add", Data: 42},
+			{Name: "delete", Data: "{"},
+		}, "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var text [][]byte
+			for _, msg := range test.msgs {
+				text = Apply(msg, text)
+			}
+			if got := join(text); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// A line split by one add must not share storage with its second half, or a
+// later add to the end of the first half would overwrite it.
+func TestApplyAddSplitAliasing(t *testing.T) {
+	text := ApplyNew("abc", nil)
+	text = ApplyAdd(Add{Line: 0, Pos: 1}, text)
+	text = ApplyAdd(Add{Line: 0, Pos: 1, Text: "X"}, text)
+	if got := join(text); got != "aX\nbc" {
+		t.Errorf("got %q, want %q", got, "aX\nbc")
+	}
+}
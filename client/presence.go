@@ -0,0 +1,55 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// PresenceVersion is the version of the Presence payload sent by this client.
+const PresenceVersion = 1
+
+// Presence is the data members enter presence with.
+type Presence struct {
+	Version int    `json:"v"`
+	Name    string `json:"name"`
+	Colour  string `json:"colour,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Client  string `json:"client,omitempty"`
+	File    string `json:"file,omitempty"`
+	Idle    bool   `json:"idle,omitempty"`
+}
+
+// Data returns p encoded to be sent with Presence.Enter or Presence.Update.
+func (p Presence) Data() string {
+	p.Version = PresenceVersion
+	js, _ := json.Marshal(p)
+	return string(js)
+}
+
+// DecodePresence reads the presence data of a member. It accepts any version
+// of the payload, as well as the bare name older clients enter with, and
+// never fails: at worst the name is the member's ClientID.
+func DecodePresence(member *ably.PresenceMessage) Presence {
+	var p Presence
+	var js []byte
+
+	switch data := member.Data.(type) {
+	case string:
+		js = []byte(data)
+		if json.Unmarshal(js, &p) != nil {
+			p = Presence{Name: data}
+		}
+	case []byte:
+		js = data
+		json.Unmarshal(js, &p)
+	case map[string]interface{}:
+		js, _ = json.Marshal(data)
+		json.Unmarshal(js, &p)
+	}
+
+	if p.Name == "" {
+		p.Name = member.ClientID
+	}
+	return p
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// Snapshot is the whole document as it was just after the message with the ID
+// After, so that replaying the history can start from it rather than from
+// the "new" that created the session. Live clients ignore snapshots.
+type Snapshot struct {
+	After   string   `json:"after"`
+	Text    string   `json:"text"`
+	Authors []string `json:"authors,omitempty"`
+	Meta    *Meta    `json:"meta,omitempty"`
+}
+
+func MakeSnapshot(after string, text [][]byte, authors []string, meta *Meta) *Snapshot {
+	return &Snapshot{After: after, Text: string(bytes.Join(text, []byte{'\n'})), Authors: authors, Meta: meta}
+}
+
+// Message returns the "new" message that replaying from the snapshot starts
+// with. The authors of each line and the metadata are carried in its extras.
+func (s *Snapshot) Message(msg *ably.Message) *ably.Message {
+	return &ably.Message{
+		ID:        msg.ID,
+		ClientID:  msg.ClientID,
+		Name:      "new",
+		Data:      s.Text,
+		Timestamp: msg.Timestamp,
+		Extras:    map[string]interface{}{"authors": s.Authors, "meta": s.Meta},
+	}
+}
+
+// NewAuthors returns the authors of each line after a "new" message, which
+// are all the sender unless it came from a snapshot.
+func NewAuthors(msg *ably.Message) []string {
+	s, _ := msg.Data.(string)
+	authors := BlameNew(s, msg.ClientID)
+	snapshot, _ := msg.Extras["authors"].([]string)
+	if len(snapshot) == len(authors) {
+		return snapshot
+	}
+	return authors
+}
+
+func (s *Snapshot) Data() []byte {
+	// work around ably bug
+	js, _ := json.Marshal(s)
+	return js
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Command is something that can be run from the command line or a key by
+// name. Complete, if set, returns what the last argument could be completed
+// to. Global commands work from any view, and when replaying.
+type Command struct {
+	Name     string
+	Global   bool
+	Args     string
+	Help     string
+	Run      func(l *Layout, args []string) error
+	Complete func(l *Layout, arg string) (string, []string)
+}
+
+// action makes a command from a keybinding handler.
+func action(f func(*Layout, *gocui.Gui, *gocui.View) error) func(*Layout, []string) error {
+	return func(l *Layout, args []string) error {
+		return f(l, l.Editor.Gui, l.Editor.View())
+	}
+}
+
+// motion makes a command that moves the cursor like the arrow keys.
+func motion(move func(*Editor)) func(*Layout, []string) error {
+	return func(l *Layout, args []string) error {
+		e := l.Editor
+		e.EditMux.Lock()
+		defer e.EditMux.Unlock()
+		if e.interact() {
+			move(e)
+		}
+		return nil
+	}
+}
+
+func completeFile(l *Layout, arg string) (string, []string) {
+	typed, matches, _ := completePath(arg)
+	return typed, matches
+}
+
+func completeMember(l *Layout, arg string) (string, []string) {
+	var names []string
+	for _, member := range l.Members {
+		if member.ClientID != l.Id {
+			names = append(names, memberName(member))
+		}
+	}
+	return completeWord(arg, names)
+}
+
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		{Name: "backward-char", Help: "Move left", Run: motion((*Editor).charLeft)},
+		{Name: "backward-word", Help: "Move to the start of the word", Run: motion((*Editor).wordLeft)},
+		{Name: "beginning-of-document", Help: "Move to the start of the document", Run: motion((*Editor).docStart)},
+		{Name: "beginning-of-line", Help: "Move to the start of the line", Run: motion((*Editor).lineStart)},
+		{Name: "blame", Global: true, Help: "Toggle who last changed each line", Run: action((*Layout).toggleBlame)},
+		{Name: "command", Help: "Open the command line", Run: func(l *Layout, args []string) error {
+			return l.openCommand("")(l.Editor.Gui, l.Editor.View())
+		}},
+		{Name: "end", Help: "End the session for everyone", Run: action((*Layout).endSession)},
+		{Name: "end-of-document", Help: "Move to the end of the document", Run: motion((*Editor).docEnd)},
+		{Name: "end-of-line", Help: "Move to the end of the line", Run: motion((*Editor).lineEnd)},
+		{Name: "export", Args: "[file]", Help: "Export the session as html or md", Run: (*Layout).exportCommand, Complete: completeFile},
+		{Name: "forward-char", Help: "Move right", Run: motion((*Editor).charRight)},
+		{Name: "forward-word", Help: "Move to the next word", Run: motion((*Editor).wordRight)},
+		{Name: "follow", Args: "<member>", Help: "Follow a member's cursor", Run: (*Layout).followCommand, Complete: completeMember},
+		{Name: "goto", Args: "<line>", Help: "Go to a line", Run: (*Layout).gotoCommand},
+		{Name: "help", Help: "List the commands", Run: (*Layout).helpCommand},
+		{Name: "history", Help: "Show the session's history", Run: action((*Layout).showHistory)},
+		{Name: "log", Global: true, Help: "Toggle the log", Run: action((*Layout).toggleLog)},
+		{Name: "mark", Help: "Start or stop selecting while presenting", Run: action((*Layout).toggleMark)},
+		{Name: "members", Help: "Pick a member to follow or make host", Run: action((*Layout).pickMember)},
+		{Name: "new", Help: "Clear the document for everyone", Run: func(l *Layout, args []string) error {
+			return l.requestReset(false)(l.Editor.Gui, l.Editor.View())
+		}},
+		{Name: "next-line", Help: "Move down", Run: motion((*Editor).lineDown)},
+		{Name: "next-member", Help: "Jump to the next member's cursor", Run: action((*Layout).nextMember)},
+		{Name: "open", Args: "[file]", Help: "Open a file into the session", Run: func(l *Layout, args []string) error {
+			err := l.openFile(l.Editor.Gui, l.Editor.View())
+			if l.Save != nil {
+				l.Save.Name = strings.Join(args, " ")
+			}
+			return err
+		}, Complete: completeFile},
+		{Name: "page-down", Help: "Move down a page", Run: motion((*Editor).pageDown)},
+		{Name: "page-up", Help: "Move up a page", Run: motion((*Editor).pageUp)},
+		{Name: "present", Help: "Start or stop presenting", Run: action((*Layout).togglePresenting)},
+		{Name: "previous-line", Help: "Move up", Run: motion((*Editor).lineUp)},
+		{Name: "quit", Global: true, Help: "Quit, asking first if there are unsaved changes", Run: action((*Layout).quit)},
+		{Name: "replace-all", Global: true, Help: "Replace every match of the search", Run: func(l *Layout, args []string) error {
+			if l.Search == nil || !l.Search.Replacing {
+				return errors.New("Search and press Tab to enter a replacement first")
+			}
+			l.Search.replace(true)
+			return nil
+		}},
+		{Name: "request-save", Help: "Ask the host to save", Run: action((*Layout).requestSave)},
+		{Name: "restore", Help: "Restore the document from before the last reset", Run: func(l *Layout, args []string) error {
+			return l.requestReset(true)(l.Editor.Gui, l.Editor.View())
+		}},
+		{Name: "save", Help: "Save the document", Run: func(l *Layout, args []string) error {
+			l.Save = &Save{Editor: l.Editor}
+			return nil
+		}},
+		{Name: "save-as", Args: "[file]", Help: "Save the document under a new name", Run: func(l *Layout, args []string) error {
+			l.Save = &Save{Editor: l.Editor, Force: true, Name: strings.Join(args, " ")}
+			return nil
+		}, Complete: completeFile},
+		{Name: "search", Args: "[text]", Help: "Search the document", Run: func(l *Layout, args []string) error {
+			l.openSearch(l.Editor.Gui, l.Editor.View())
+			l.Search.Query = strings.Join(args, " ")
+			l.Search.find()
+			l.Search.first()
+			return nil
+		}},
+		{Name: "snapshot", Help: "Take a snapshot of the document", Run: action((*Layout).takeSnapshot)},
+		{Name: "vim", Help: "Toggle vim style editing", Run: (*Layout).toggleVim},
+	}
+}
+
+func findCommand(name string) *Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+func (l *Layout) gotoCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: goto <line>")
+	}
+	line, err := strconv.Atoi(args[0])
+	if err != nil {
+		return errors.New("Not a line number: " + args[0])
+	}
+	e := l.Editor
+	e.EditMux.Lock()
+	defer e.EditMux.Unlock()
+	if e.interact() {
+		e.navigate(0, line-1)
+	}
+	return nil
+}
+
+func (l *Layout) followCommand(args []string) error {
+	name := strings.Join(args, " ")
+	for _, member := range l.Members {
+		if member.ClientID == l.Id || !strings.EqualFold(memberName(member), name) && member.ClientID != name {
+			continue
+		}
+		l.Following = member.ClientID
+		l.Editor.Nodify("Following " + memberName(member) + ", type or move to stop")
+		l.followCursor(l.Following)
+		return nil
+	}
+	return errors.New("No member called " + name)
+}
+
+func (l *Layout) exportCommand(args []string) error {
+	options := &Arguments{Arg: l.Code, Out: strings.Join(args, " ")}
+	if options.Out == "" {
+		options.Out = l.Code + ".html"
+	}
+	if strings.ToLower(filepath.Ext(options.Out)) == ".md" {
+		options.Format = "md"
+	}
+	options.Out = expandHome(options.Out)
+	go func() {
+		err := export(context.Background(), l.Editor.Channel, options)
+		if err != nil {
+			l.Editor.Nodify(err.Error())
+		} else {
+			l.Editor.Nodify("Exported to " + options.Out)
+		}
+	}()
+	return nil
+}
+
+func (l *Layout) helpCommand(args []string) error {
+	log := l.Editor.Log()
+	for _, command := range commands {
+		help := command.Help
+		if keys := l.Keymap.keysFor(command.Name); keys != "" {
+			help += " (" + keys + ")"
+		}
+		fmt.Fprintf(log, "%-24s %s\n", strings.TrimSpace(command.Name+" "+command.Args), help)
+	}
+	fmt.Fprintln(log)
+	for _, key := range editKeys {
+		fmt.Fprintf(log, "%-24s %s\n", key.Keys, key.Help)
+	}
+	if !l.Log {
+		l.toggleLog(l.Editor.Gui, nil)
+	}
+	return nil
+}
+
+// CommandLine reads a command and runs it, with tab completion of command
+// names and their arguments.
+type CommandLine struct {
+	Editor  *Editor
+	Value   string
+	Message string
+}
+
+func (l *Layout) openCommand(value string) func(*gocui.Gui, *gocui.View) error {
+	return func(gui *gocui.Gui, v *gocui.View) error {
+		l.Command = &CommandLine{Editor: l.Editor, Value: value}
+		return nil
+	}
+}
+
+func (c *CommandLine) Layout(gui *gocui.Gui) error {
+	maxX, maxY := gui.Size()
+	input, err := gui.SetView("command", 0, maxY-3, maxX-1, maxY-1)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		input.Editable = true
+		input.Editor = c
+		fmt.Fprint(input, c.Value)
+		input.SetCursor(len(c.Value), 0)
+	}
+	input.Title = "Command"
+	if c.Message != "" {
+		input.Title += ": " + c.Message
+	}
+	gui.SetCurrentView("command")
+	return nil
+}
+
+func (c *CommandLine) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	switch {
+	case key == gocui.KeyEsc:
+		c.Close()
+	case key == gocui.KeyEnter:
+		c.Close()
+		c.run(strings.TrimSpace(v.Buffer()))
+	case key == gocui.KeyTab:
+		c.complete(v)
+	default:
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+		c.Message = ""
+	}
+}
+
+func (c *CommandLine) run(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	command := findCommand(fields[0])
+	if command == nil {
+		c.Editor.Nodify("Unknown command " + fields[0] + ", try help")
+		return
+	}
+
+	err := command.Run(c.Editor.Layout, fields[1:])
+	if err == gocui.ErrQuit {
+		c.Editor.Gui.Update(quit)
+	} else if err != nil {
+		c.Editor.Nodify(err.Error())
+	}
+}
+
+// complete completes the command name, or the last argument if the command
+// knows how to.
+func (c *CommandLine) complete(v *gocui.View) {
+	line := strings.TrimLeft(strings.TrimRight(v.Buffer(), "\n"), " ")
+	var completed string
+	var matches []string
+
+	space := strings.Index(line, " ")
+	if space < 0 {
+		var names []string
+		for _, command := range commands {
+			names = append(names, command.Name)
+		}
+		completed, matches = completeWord(line, names)
+		if len(matches) == 1 {
+			completed += " "
+		}
+	} else {
+		command := findCommand(line[:space])
+		if command == nil || command.Complete == nil {
+			return
+		}
+		var arg string
+		arg, matches = command.Complete(c.Editor.Layout, strings.TrimLeft(line[space:], " "))
+		completed = line[:space+1] + arg
+	}
+
+	sort.Strings(matches)
+	if len(matches) > 1 {
+		c.Message = strings.Join(matches, " ")
+	} else if len(matches) == 0 {
+		c.Message = "No matches"
+	} else {
+		c.Message = ""
+	}
+	v.Clear()
+	fmt.Fprint(v, completed)
+	v.SetCursor(len(completed), 0)
+}
+
+func (c *CommandLine) Close() {
+	c.Editor.Gui.DeleteView("command")
+	c.Editor.Layout.Command = nil
+}
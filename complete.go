@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// completePath completes a file path as far as it can, returning it along with
+// the names in its directory that it could be completed to. Hidden files are
+// only offered once a "." has been typed.
+func completePath(typed string) (string, []string, error) {
+	if typed == "~" {
+		typed += string(filepath.Separator)
+	}
+	path := expandHome(typed)
+	if strings.HasSuffix(typed, string(filepath.Separator)) && !strings.HasSuffix(path, string(filepath.Separator)) {
+		// expandHome cleans the path, which loses the separator that says to
+		// list the directory rather than complete its name.
+		path += string(filepath.Separator)
+	}
+	dir, prefix := filepath.Split(path)
+	list := dir
+	if list == "" {
+		list = "."
+	}
+
+	entries, err := os.ReadDir(list)
+	if err != nil {
+		return typed, nil, err
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, name)
+	}
+	if len(matches) == 0 {
+		return typed, nil, nil
+	}
+	return typed[:len(typed)-len(prefix)] + commonPrefix(matches, false), matches, nil
+}
+
+// completeWord completes typed to the longest prefix shared by the words
+// starting with it, ignoring case, returning it and those words. The words'
+// case is used if they agree on it, otherwise what was typed keeps its case.
+func completeWord(typed string, words []string) (string, []string) {
+	var matches []string
+	for _, word := range words {
+		if len(sharedPrefix(word, typed, true)) == len(typed) {
+			matches = append(matches, word)
+		}
+	}
+	if len(matches) == 0 {
+		return typed, nil
+	}
+	if exact := commonPrefix(matches, false); len(exact) >= len(typed) {
+		return exact, matches
+	}
+	return typed + commonPrefix(matches, true)[len(typed):], matches
+}
+
+// commonPrefix returns the longest prefix of the first word that all the words
+// start with, ignoring case if fold is set.
+func commonPrefix(words []string, fold bool) string {
+	common := words[0]
+	for _, word := range words[1:] {
+		common = sharedPrefix(common, word, fold)
+	}
+	return common
+}
+
+// sharedPrefix returns the longest prefix of a that b starts with, comparing
+// whole runes and ignoring case if fold is set. Runes are only the same if
+// they are the same length, so the prefix is as long in b as in a.
+func sharedPrefix(a, b string, fold bool) string {
+	n := 0
+	for n < len(a) && n < len(b) {
+		ra, size := utf8.DecodeRuneInString(a[n:])
+		rb, sizeB := utf8.DecodeRuneInString(b[n:])
+		if size != sizeB || ra != rb && !(fold && strings.EqualFold(string(ra), string(rb))) {
+			break
+		}
+		n += size
+	}
+	return a[:n]
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompleteWord(t *testing.T) {
+	tests := []struct {
+		name    string
+		typed   string
+		words   []string
+		want    string
+		matches []string
+	}{
+		{"common prefix", "fo", []string{"foo", "foobar", "bar"}, "foo", []string{"foo", "foobar"}},
+		{"no match", "x", []string{"foo", "bar"}, "x", nil},
+		{"nothing typed", "", []string{"ab", "ac"}, "a", []string{"ab", "ac"}},
+		{"whole word", "foo", []string{"foo"}, "foo", []string{"foo"}},
+		{"words' case", "FO", []string{"foo", "foobar"}, "foo", []string{"foo", "foobar"}},
+		{"mixed case keeps what was typed", "Fo", []string{"foobar", "FOOBAZ"}, "Fooba", []string{"foobar", "FOOBAZ"}},
+		{"mixed case from the first letter", "f", []string{"Foo", "fOO"}, "foo", []string{"Foo", "fOO"}},
+		{"multi-byte", "é", []string{"école", "éclair"}, "éc", []string{"école", "éclair"}},
+		{"multi-byte case", "É", []string{"école", "éclair"}, "éc", []string{"école", "éclair"}},
+		{"multi-byte mixed case", "é", []string{"école", "École"}, "école", []string{"école", "École"}},
+		{"multi-byte after the prefix", "na", []string{"naïve", "naïf"}, "naï", []string{"naïve", "naïf"}},
+		{"runes of different lengths", "k", []string{"Kelvin", "kilo"}, "kilo", []string{"kilo"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, matches := completeWord(test.typed, test.words)
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if fmt.Sprint(matches) != fmt.Sprint(test.matches) {
+				t.Errorf("got matches %q, want %q", matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alpha.txt", "beta", ".hidden", "Ärger"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	os.Mkdir(filepath.Join(dir, "alpine"), 0755)
+	t.Setenv("HOME", dir)
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		name    string
+		typed   string
+		want    string
+		matches []string
+	}{
+		{"common prefix", dir + sep + "al", dir + sep + "alp", []string{"alpha.txt", "alpine" + sep}},
+		{"file", dir + sep + "be", dir + sep + "beta", []string{"beta"}},
+		{"directory", dir + sep + "alpi", dir + sep + "alpine" + sep, []string{"alpine" + sep}},
+		{"hidden files", dir + sep, dir + sep, []string{"alpha.txt", "alpine" + sep, "beta", "Ärger"}},
+		{"dot", dir + sep + ".", dir + sep + ".hidden", []string{".hidden"}},
+		{"multi-byte", dir + sep + "Är", dir + sep + "Ärger", []string{"Ärger"}},
+		{"case matters", dir + sep + "AL", dir + sep + "AL", nil},
+		{"no match", dir + sep + "zz", dir + sep + "zz", nil},
+		{"home", "~" + sep + "al", "~" + sep + "alp", []string{"alpha.txt", "alpine" + sep}},
+		{"home itself", "~", "~" + sep, []string{"alpha.txt", "alpine" + sep, "beta", "Ärger"}},
+		{"home directory", "~" + sep, "~" + sep, []string{"alpha.txt", "alpine" + sep, "beta", "Ärger"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, matches, err := completePath(test.typed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if fmt.Sprint(matches) != fmt.Sprint(test.matches) {
+				t.Errorf("got matches %q, want %q", matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestCompletePathMissingDir(t *testing.T) {
+	typed := filepath.Join(t.TempDir(), "missing", "a")
+	got, _, err := completePath(typed)
+	if err == nil {
+		t.Error("got no error completing in a directory that doesn't exist")
+	}
+	if got != typed {
+		t.Errorf("got %q, want what was typed", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config is read from sync-edit/config.json in the user's config directory.
+// A missing file is the same as an empty one.
+type Config struct {
+	// Backup keeps the previous version of a file as file~ when saving.
+	Backup bool `json:"backup"`
+	// Keymap is the built in keymap to start from, "nano" (the default) or
+	// "emacs", and Keys binds or, given "", unbinds keys on top of it.
+	Keymap string            `json:"keymap"`
+	Keys   map[string]string `json:"keys"`
+	// Vim starts the editor in vim mode.
+	Vim bool `json:"vim"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sync-edit", "config.json"), nil
+}
+
+func loadConfig() (*Config, error) {
+	config := &Config{}
+	path, err := configPath()
+	if err != nil {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	_, err = makeKeymap(config.Keymap, config.Keys)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return config, nil
+}
+
+func (c *Config) backup() bool {
+	return c != nil && c.Backup
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+func (l *Layout) handleCursor(msg *ably.Message) {
+	var cursor client.Cursor
+	err := client.Decode(msg, &cursor)
+	if err != nil {
+		return
+	}
+	l.Cursors[msg.ClientID] = cursor
+	if msg.ClientID == l.leader() {
+		l.followCursor(msg.ClientID)
+	}
+}
+
+// handlePresence forgets the cursor of a member who has left.
+func (l *Layout) handlePresence(msg *ably.PresenceMessage) {
+	if msg.Action == ably.PresenceActionLeave || msg.Action == ably.PresenceActionAbsent {
+		delete(l.Cursors, msg.ClientID)
+		if msg.ClientID == l.Following {
+			l.Following = ""
+			l.Editor.Nodify("Stopped following, they left")
+		}
+		if msg.ClientID == l.Presenter {
+			l.stopPresenting()
+		}
+	}
+}
+
+// visibleCursors returns the cursors of the other members that are present
+// and inside the editor view, relative to the top left of the view, given its
+// origin and size.
+func (l *Layout) visibleCursors(xo, yo, xs, ys int) map[string]client.Cursor {
+	visible := make(map[string]client.Cursor)
+	for _, member := range l.Members {
+		if member.ClientID == l.Id {
+			continue
+		}
+
+		pos, ok := l.Cursors[member.ClientID]
+		if !ok {
+			continue
+		}
+
+		x := pos.X - xo
+		y := pos.Y - yo
+		if x >= 0 && x < xs && y >= 0 && y < ys {
+			visible[member.ClientID] = client.Cursor{X: x, Y: y}
+		}
+	}
+	return visible
+}
+
+func (l *Layout) layoutCursors(gui *gocui.Gui, editor *gocui.View) error {
+	left, top, _, _, err := gui.ViewPosition("editor")
+	if err != nil {
+		return err
+	}
+	xo, yo := editor.Origin()
+	xs, ys := editor.Size()
+	visible := l.visibleCursors(xo, yo, xs, ys)
+
+	var stale []string
+	for _, view := range gui.Views() {
+		name := view.Name()
+		if strings.HasPrefix(name, "cursor-") {
+			if _, ok := visible[strings.TrimPrefix(name, "cursor-")]; !ok {
+				stale = append(stale, name)
+			}
+		}
+	}
+	for _, name := range stale {
+		gui.DeleteView(name)
+	}
+
+	lines := editor.BufferLines()
+	for id, pos := range visible {
+		x := left + pos.X + 1
+		y := top + pos.Y + 1
+		view, err := gui.SetView("cursor-"+id, x-1, y-1, x+1, y+1)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			view.Frame = false
+		}
+		view.BgColor = l.memberColour(id)
+		view.Clear()
+		line, col := pos.Y+yo, pos.X+xo
+		if len(lines) > line && len(lines[line]) > col {
+			view.Write([]byte{lines[line][col]})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// churn keeps the members of a channel the way presence would report them,
+// and passes each change on to a Layout as main does.
+type churn struct {
+	t      *testing.T
+	layout *Layout
+}
+
+func newChurn(t *testing.T) *churn {
+	return &churn{t: t, layout: &Layout{Id: "self", Cursors: make(map[string]client.Cursor)}}
+}
+
+func (c *churn) presence(action ably.PresenceAction, id string, p client.Presence) {
+	msg := &ably.PresenceMessage{Action: action, Message: ably.Message{ClientID: id, Data: p.Data()}}
+	var members []*ably.PresenceMessage
+	for _, member := range c.layout.Members {
+		if member.ClientID != id {
+			members = append(members, member)
+		}
+	}
+	if action != ably.PresenceActionLeave {
+		members = append(members, msg)
+	}
+	c.layout.Members = members
+	c.layout.handlePresence(msg)
+}
+
+func (c *churn) enter(id string, p client.Presence) {
+	c.presence(ably.PresenceActionEnter, id, p)
+}
+
+func (c *churn) update(id string, p client.Presence) {
+	c.presence(ably.PresenceActionUpdate, id, p)
+}
+
+func (c *churn) leave(id string) {
+	c.presence(ably.PresenceActionLeave, id, client.Presence{})
+}
+
+func (c *churn) cursor(id string, x, y int) {
+	js, err := json.Marshal(client.Cursor{X: x, Y: y})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.layout.handleCursor(&ably.Message{Name: "cursor", ClientID: id, Data: js})
+}
+
+// drawn returns the colour of each cursor drawn in an 80x24 view at the
+// origin.
+func (c *churn) drawn() map[string]gocui.Attribute {
+	drawn := make(map[string]gocui.Attribute)
+	for id := range c.layout.visibleCursors(0, 0, 80, 24) {
+		drawn[id] = c.layout.memberColour(id)
+	}
+	return drawn
+}
+
+func (c *churn) expect(want map[string]gocui.Attribute) {
+	c.t.Helper()
+	got := c.drawn()
+	if len(got) != len(want) {
+		c.t.Fatalf("drew %v, want %v", got, want)
+	}
+	for id, colour := range want {
+		if got[id] != colour {
+			c.t.Fatalf("drew %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCursorChurn(t *testing.T) {
+	c := newChurn(t)
+	c.enter("self", client.Presence{Name: "me"})
+	c.enter("a", client.Presence{Name: "alice", Colour: "green"})
+	c.enter("b", client.Presence{Name: "bob", Colour: "red"})
+	c.expect(nil)
+
+	c.cursor("self", 1, 1)
+	c.cursor("a", 2, 3)
+	c.cursor("b", 4, 5)
+	c.expect(map[string]gocui.Attribute{"a": gocui.ColorGreen, "b": gocui.ColorRed})
+
+	c.leave("a")
+	c.expect(map[string]gocui.Attribute{"b": gocui.ColorRed})
+
+	// A cursor sent just before leaving can arrive after the leave.
+	c.cursor("a", 2, 4)
+	c.expect(map[string]gocui.Attribute{"b": gocui.ColorRed})
+
+	// Rejoining needs a new cursor before one is drawn.
+	c.leave("a")
+	c.enter("a", client.Presence{Name: "alice", Colour: "cyan"})
+	c.expect(map[string]gocui.Attribute{"b": gocui.ColorRed})
+	c.cursor("a", 0, 0)
+	c.expect(map[string]gocui.Attribute{"a": gocui.ColorCyan, "b": gocui.ColorRed})
+
+	c.update("b", client.Presence{Name: "bob", Colour: "magenta", Idle: true})
+	c.expect(map[string]gocui.Attribute{"a": gocui.ColorCyan, "b": gocui.ColorMagenta})
+
+	c.leave("b")
+	c.leave("a")
+	c.expect(nil)
+	if len(c.layout.Cursors) != 1 {
+		t.Errorf("kept cursors %v, want only self", c.layout.Cursors)
+	}
+}
+
+func TestCursorChurnColours(t *testing.T) {
+	c := newChurn(t)
+	c.enter("x", client.Presence{Name: "x"})
+	c.cursor("x", 0, 0)
+	hashed := c.drawn()["x"]
+
+	// A member without a chosen colour keeps the same one across rejoins,
+	// and a colour name that isn't known falls back to it.
+	c.leave("x")
+	c.enter("x", client.Presence{Name: "x", Colour: "mauve"})
+	c.cursor("x", 0, 0)
+	c.expect(map[string]gocui.Attribute{"x": hashed})
+
+	c.update("x", client.Presence{Name: "x", Colour: "blue"})
+	c.expect(map[string]gocui.Attribute{"x": gocui.ColorBlue})
+}
+
+func TestCursorChurnOutOfView(t *testing.T) {
+	c := newChurn(t)
+	c.enter("a", client.Presence{Name: "a", Colour: "green"})
+	c.cursor("a", 80, 0)
+	c.expect(nil)
+	c.cursor("a", 79, 23)
+	c.expect(map[string]gocui.Attribute{"a": gocui.ColorGreen})
+	c.cursor("a", 0, 24)
+	c.expect(nil)
+}
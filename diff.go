@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+// diffMaxCells limits the table used to find the changed lines. Past it, the
+// lines between the common prefix and suffix are shown as all removed and
+// then all added, as they are when a "new" replaces the document.
+const diffMaxCells = 1 << 20
+
+// unifiedDiff returns the lines that differ between a and b in unified diff
+// format, or an empty string if they are the same.
+func unifiedDiff(a, b []string) string {
+	// Most updates change a few lines in one place, so only compare what lies
+	// between the common prefix and suffix.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:] and
+	// mb[j:], left all zero if it would be too big so that nothing matches.
+	lcs := make([][]int, len(ma)+1)
+	if (len(ma)+1)*(len(mb)+1) <= diffMaxCells {
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+	}
+	matched := func(i, j int) bool {
+		return lcs[i] != nil && ma[i] == mb[j]
+	}
+	removes := func(i, j int) bool {
+		return lcs[i] == nil || lcs[i+1][j] >= lcs[i][j+1]
+	}
+
+	type edit struct {
+		kind byte
+		line string
+		a, b int
+	}
+	var edits []edit
+	for i := 0; i < pre; i++ {
+		edits = append(edits, edit{' ', a[i], i, i})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && matched(i, j):
+			edits = append(edits, edit{' ', ma[i], pre + i, pre + j})
+			i, j = i+1, j+1
+		case i < len(ma) && (j == len(mb) || removes(i, j)):
+			edits = append(edits, edit{'-', ma[i], pre + i, pre + j})
+			i++
+		default:
+			edits = append(edits, edit{'+', mb[j], pre + i, pre + j})
+			j++
+		}
+	}
+	for k := 0; k < suf; k++ {
+		edits = append(edits, edit{' ', a[len(a)-suf+k], len(a) - suf + k, len(b) - suf + k})
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		if edits[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// Grow the hunk until there are more than two contexts worth of
+		// unchanged lines after the last change.
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for k := start; k < len(edits) && k-end <= 2*diffContext+1; k++ {
+			if edits[k].kind != ' ' {
+				end = k
+			}
+		}
+		last := end + diffContext
+		if last >= len(edits) {
+			last = len(edits) - 1
+		}
+
+		aCount, bCount := 0, 0
+		for _, e := range edits[first : last+1] {
+			if e.kind != '+' {
+				aCount++
+			}
+			if e.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", edits[first].a+1, aCount, edits[first].b+1, bCount)
+		for _, e := range edits[first : last+1] {
+			fmt.Fprintf(&out, "%c%s\n", e.kind, e.line)
+		}
+		start = last + 1
+	}
+	return out.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb", "a\nb", ""},
+		{"both empty", "", "", ""},
+		{"from empty", "", "a\nb", "@@ -1,1 +1,2 @@\n-\n+a\n+b\n"},
+		{"to empty", "a\nb", "", "@@ -1,2 +1,1 @@\n-a\n-b\n+\n"},
+		{"trailing newline added", "a\nb", "a\nb\n", "@@ -1,2 +1,3 @@\n a\n b\n+\n"},
+		{"trailing newline removed", "a\nb\n", "a\nb", "@@ -1,3 +1,2 @@\n a\n b\n-\n"},
+		{
+			"context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9",
+			"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"context at the edges",
+			"1\n2\n3",
+			"one\n2\nthree",
+			"@@ -1,3 +1,3 @@\n-1\n+one\n 2\n-3\n+three\n",
+		},
+		{
+			"close changes share a hunk",
+			"1\n2\n3\n4\n5\n6\n7\n8",
+			"one\n2\n3\n4\n5\n6\n7\neight",
+			"@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+		{
+			"distant changes get their own hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\n10\neleven",
+			"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -8,4 +8,4 @@\n 8\n 9\n 10\n-11\n+eleven\n",
+		},
+		{
+			"insert in the middle",
+			"a\nb\nc",
+			"a\nb\nx\nc",
+			"@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := unifiedDiff(strings.Split(test.a, "\n"), strings.Split(test.b, "\n"))
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// patch applies a diff from unifiedDiff to a, checking its hunks as it goes.
+func patch(t *testing.T, a []string, diff string) []string {
+	var b []string
+	at := 0
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "@@") {
+			var start, count int
+			_, err := fmt.Sscanf(line, "@@ -%d,%d", &start, &count)
+			if err != nil {
+				t.Fatalf("bad hunk header %q", line)
+			}
+			b = append(b, a[at:start-1]...)
+			at = start - 1
+			continue
+		}
+		switch line[0] {
+		case ' ', '-':
+			if a[at] != line[1:] {
+				t.Fatalf("diff expects %q at line %d, found %q", line[1:], at+1, a[at])
+			}
+			if line[0] == ' ' {
+				b = append(b, a[at])
+			}
+			at++
+		case '+':
+			b = append(b, line[1:])
+		}
+	}
+	return append(b, a[at:]...)
+}
+
+func TestUnifiedDiffTooBig(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 2000; i++ {
+		a = append(a, fmt.Sprint("a", i))
+		b = append(b, fmt.Sprint("b", i))
+	}
+	a[1000], b[1000] = "same", "same"
+	a = append([]string{"first"}, append(a, "last")...)
+	b = append([]string{"first"}, append(b, "last")...)
+
+	diff := unifiedDiff(a, b)
+	if !strings.HasPrefix(diff, "@@ -1,2002 +1,2002 @@\n first\n-a0\n") {
+		t.Errorf("expected one hunk replacing everything but the first and last lines, got %.60q", diff)
+	}
+	got := patch(t, a, diff)
+	if strings.Join(got, "\n") != strings.Join(b, "\n") {
+		t.Error("applying the diff didn't give the new text")
+	}
+}
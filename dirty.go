@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/jroimartin/gocui"
+)
+
+func textHash(text [][]byte) [sha256.Size]byte {
+	return sha256.Sum256(bytes.Join(text, []byte{'\n'}))
+}
+
+// dirty reports whether the document differs from what this client last
+// saved, or opened from disk. A member who has never saved is only dirty once
+// they have made changes of their own.
+func (e *Editor) dirty() bool {
+	if e.EditBuffer != nil || len(e.Pending) > 0 {
+		return true
+	}
+	if e.Saved == nil {
+		return e.Edited
+	}
+	return textHash(e.Text) != *e.Saved
+}
+
+func (e *Editor) markSaved() {
+	hash := textHash(e.Text)
+	e.Saved = &hash
+	e.Edited = false
+}
+
+const quitLabel = "Unsaved changes: (s)ave, (d)iscard or (c)ancel?"
+
+// quit exits straight away unless there are unsaved changes, in which case it
+// asks whether to save them first.
+func (l *Layout) quit(gui *gocui.Gui, v *gocui.View) error {
+	if l.Player != nil || !l.Editor.dirty() {
+		return gocui.ErrQuit
+	}
+	if l.Prompt != nil && l.Prompt.Label == quitLabel {
+		return nil
+	}
+
+	l.ask(&Prompt{
+		Editor: l.Editor,
+		Label:  quitLabel,
+		OnEnter: func(value string) error {
+			switch value {
+			case "s", "save":
+				l.Save = &Save{Editor: l.Editor, Quit: true}
+			case "d", "discard":
+				gui.Update(quit)
+			case "c", "cancel", "":
+			default:
+				return errors.New("Type s to save, d to discard or c to cancel")
+			}
+			return nil
+		},
+	})
+	return nil
+}
+
+func quit(g *gocui.Gui) error {
+	return gocui.ErrQuit
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ably-labs/sync-edit/client"
 	"github.com/ably/ably-go/ably"
 	"github.com/jroimartin/gocui"
 )
@@ -32,43 +33,71 @@ import (
 type Editor struct {
 	Layout     *Layout
 	EditBuffer interface{}
-	LastCursor Cursor
+	LastCursor client.Cursor
 	EditMux    sync.Mutex
 	Text       [][]byte
+	Authors    []string
 	Channel    *ably.RealtimeChannel
 	Gui        *gocui.Gui
 	Cursors    map[string]gocui.View
 	Quit       chan struct{}
+	Done       chan struct{}
+	Saved      *[sha256.Size]byte
+	Edited     bool
+	Meta       client.Meta
+	DiskFile   string
+	DiskHash   [sha256.Size]byte
 	Queue      chan interface{}
+	Pending    []interface{}
+	Version    int
+	LastId     string
+	Ops        int
+	Presence   client.Presence
+	PresMux    sync.Mutex
+	PresQueue  chan client.Presence
+	LastActive time.Time
 }
 
-type Add struct {
-	Line int    `json:"line"`
-	Pos  int    `json:"pos"`
-	Text string `json:"text"`
-}
+// The host publishes a snapshot of the document after this many ops, so that
+// joining doesn't mean replaying the whole history.
+const snapshotEvery = 500
 
-type Delete struct {
-	Line  int `json:"line"`
-	Pos   int `json:"pos"`
-	Count int `json:"count"`
-}
+// Members are shown as idle when they haven't typed or moved for this long.
+const idleAfter = 5 * time.Minute
 
 func MakeEditor(ctx context.Context, text []byte, owner bool, channel *ably.RealtimeChannel, gui *gocui.Gui, layout *Layout) (*Editor, error) {
-	edit := &Editor{Channel: channel, Gui: gui, Layout: layout}
+	edit := &Editor{Channel: channel, Gui: gui, Layout: layout, LastActive: time.Now()}
+	edit.Queue = make(chan interface{}, 100)
+	edit.PresQueue = make(chan client.Presence, 1)
+	edit.Quit = make(chan struct{})
+	edit.Done = make(chan struct{})
 
+	var seq client.Sequence
 	_, err := channel.SubscribeAll(ctx, func(msg *ably.Message) {
-		edit.handleMessage(msg)
+		if seq.Accept(msg) {
+			edit.handleMessage(msg)
+		}
 	})
 
 	edit.Text = [][]byte{{}}
 
 	if owner {
-		err = edit.Channel.Publish(context.Background(), "new", string(text))
+		js, _ := json.Marshal(&client.ResetResult{Approved: true})
+		err = edit.Channel.PublishMultiple(context.Background(), []*ably.Message{
+			{Name: "reset-result", Data: js},
+			{Name: "new", Data: string(text)},
+		})
 		if err != nil {
 			return nil, err
 		}
-		edit.Text = applyNew(string(text), edit.Text)
+		edit.Text = client.ApplyNew(string(text), edit.Text)
+		edit.markSaved()
+		if layout.FileName != "" {
+			edit.onDisk(layout.FileName, text)
+		}
+		edit.Meta = client.DetectMeta(layout.FileName, text)
+		meta := edit.Meta
+		edit.Queue <- &meta
 		edit.Layout.Editable = true
 	} else {
 		err = edit.initFromHistory(ctx)
@@ -79,6 +108,7 @@ func MakeEditor(ctx context.Context, text []byte, owner bool, channel *ably.Real
 
 	go edit.publishQueue()
 	go edit.editLoop()
+	go edit.presenceLoop()
 
 	/*gui.SetKeybinding("", gocui.KeyCtrlSpace, gocui.ModNone, func(gui *gocui.Gui, view *gocui.View) error {
 		log, _ := gui.View("log")
@@ -90,29 +120,78 @@ func MakeEditor(ctx context.Context, text []byte, owner bool, channel *ably.Real
 }
 
 func (e *Editor) publishQueue() {
-	e.Queue = make(chan interface{}, 100)
 	buffer := make([]*ably.Message, 0)
+	var ops []interface{}
+	// Snapshots are published on their own, so that one failing, as a large
+	// one might, doesn't lose the edits published with it.
+	var snapshots []*ably.Message
 	ctx := context.Background()
 
-	buffChange := func(msg interface{}) {
+	var buffChange func(msg interface{})
+	buffChange = func(msg interface{}) {
 		switch edit := msg.(type) {
-		case *Add:
+		case []interface{}:
+			for _, op := range edit {
+				buffChange(op)
+			}
+		case *client.Add:
 			// work around ably bug
 			js, _ := json.Marshal(edit)
 			buffer = append(buffer, &ably.Message{Name: "add", Data: js})
-		case *Delete:
+			ops = append(ops, edit)
+		case *client.Delete:
 			// work around ably bug
 			js, _ := json.Marshal(edit)
 			buffer = append(buffer, &ably.Message{Name: "delete", Data: js})
-		case *Cursor:
+			ops = append(ops, edit)
+		case *client.Cursor:
 			// work around ably bug
 			js, _ := json.Marshal(edit)
 			buffer = append(buffer, &ably.Message{Name: "cursor", Data: js})
+		case *client.Presentation:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "present", Data: js})
+		case *client.Handover:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "host", Data: js})
+		case *client.Snapshot:
+			snapshots = append(snapshots, &ably.Message{Name: "snapshot", Data: edit.Data()})
+		case *client.Saved:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "saved", Data: js})
+		case *client.SaveRequest:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "save-request", Data: js})
+		case *client.SaveResult:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "save-result", Data: js})
+		case *client.Meta:
+			buffer = append(buffer, &ably.Message{Name: "meta", Data: edit.Data()})
+		case *ably.Message:
+			buffer = append(buffer, edit)
+		case *client.ResetRequest:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "reset-request", Data: js})
+		case *client.ResetVote:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "reset-vote", Data: js})
+		case *client.ResetResult:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "reset-result", Data: js})
+		case *client.End:
+			js, _ := json.Marshal(edit)
+			buffer = append(buffer, &ably.Message{Name: "end", Data: js})
 		}
 	}
 
 	for {
-		buffChange(<-e.Queue)
+		quit := false
+		select {
+		case msg := <-e.Queue:
+			buffChange(msg)
+		case <-e.Quit:
+			quit = true
+		}
 
 	f:
 		for {
@@ -124,75 +203,70 @@ func (e *Editor) publishQueue() {
 			}
 		}
 
-		err := e.Channel.PublishMultiple(ctx, buffer)
-		if err != nil {
-			e.Nodify(err.Error())
+		if len(buffer) != 0 {
+			err := e.Channel.PublishMultiple(ctx, buffer)
+			if err != nil {
+				e.Nodify(err.Error())
+				failed := ops
+				e.Gui.Update(func(gui *gocui.Gui) error {
+					e.EditMux.Lock()
+					e.dropPending(failed)
+					e.EditMux.Unlock()
+					return nil
+				})
+			}
+			buffer, ops = nil, nil
 		}
-		buffer = nil
-	}
-}
-
-func applyAdd(add Add, text [][]byte) [][]byte {
-	if add.Line < 0 || add.Line >= len(text) || add.Pos < 0 || add.Pos > len(text[add.Line]) {
-		return text
-	}
-
-	if add.Text == "" {
-		text = append(text[:add.Line+1], text[add.Line:]...)
-		text[add.Line+1] = text[add.Line][add.Pos:]
-		text[add.Line] = text[add.Line][:add.Pos]
-	} else if add.Pos == len(text[add.Line]) {
-		text[add.Line] = append(text[add.Line], []byte(add.Text)...)
-	} else {
-		end := append([]byte(nil), text[add.Line][add.Pos:]...)
-		text[add.Line] = append(text[add.Line][:add.Pos], []byte(add.Text)...)
-		text[add.Line] = append(text[add.Line], end...)
-	}
-
-	return text
-}
-
-func applyNew(s string, text [][]byte) [][]byte {
-	return bytes.Split([]byte(s), []byte{'\n'})
-}
-
-func applyDel(del Delete, text [][]byte) [][]byte {
-	if del.Line < 0 || del.Line >= len(text) || del.Pos < 0 || del.Count < 0 {
-		return text
-	}
-	if del.Count != 0 && del.Count+del.Pos-1 >= len(text[del.Line]) {
-		return text
-	}
-	if del.Count == 0 && del.Line >= len(text) {
-		return text
-	}
+		for _, snapshot := range snapshots {
+			err := e.Channel.Publish(ctx, snapshot.Name, snapshot.Data)
+			if err != nil {
+				e.Nodify("Snapshot failed: " + err.Error())
+			}
+		}
+		snapshots = nil
 
-	if del.Count == 0 {
-		line := text[del.Line]
-		text = append(text[:del.Line], text[del.Line+1:]...)
-		text[del.Line] = append(line, text[del.Line]...)
-	} else {
-		text[del.Line] = append(text[del.Line][:del.Pos], text[del.Line][del.Pos+del.Count:]...)
+		if quit {
+			close(e.Done)
+			return
+		}
 	}
-	return text
-
 }
 
+// handleMessage applies a message from the channel, or its history, to the
+// document. Every message goes through here in the order it is applied, so
+// this is where they are recorded.
 func (e *Editor) handleMessage(msg *ably.Message) {
 	e.EditMux.Lock()
 	defer e.EditMux.Unlock()
+	if e.Layout.Recorder != nil {
+		e.Layout.Recorder.Message(msg)
+	}
+	e.LastId = msg.ID
+	if msg.Name == "new" || msg.Name == "add" || msg.Name == "delete" {
+		e.Version++
+		if msg.ClientID == e.Layout.Id {
+			e.Edited = true
+		}
+		if msg.ClientID == e.Layout.Id && msg.Name != "new" && len(e.Pending) > 0 {
+			e.Pending = e.Pending[1:]
+		}
+	}
 	switch msg.Name {
 	case "new":
+		e.Ops = 0
 		text := msg.Data.(string)
 		e.Layout.Editable = true
 		e.EditBuffer = nil
-		e.Text = applyNew(text, e.Text)
+		e.Authors = client.NewAuthors(msg)
+		e.Text = client.ApplyNew(text, e.Text)
+		if meta := client.MetaOf(msg); meta != nil {
+			e.Meta = *meta
+		}
 		e.Layout.Redraw = true
 		e.View().SetCursor(0, 0)
 	case "add":
-		var add Add
-		data := msg.Data.(string)
-		err := json.Unmarshal([]byte(data), &add)
+		var add client.Add
+		err := client.Decode(msg, &add)
 		if err != nil {
 			break
 		}
@@ -211,12 +285,13 @@ func (e *Editor) handleMessage(msg *ably.Message) {
 				e.View().SetCursor(x-xo+len(add.Text), y-yo)
 			}
 		}
-		e.Text = applyAdd(add, e.Text)
+		e.Authors = client.BlameAdd(add, msg.ClientID, e.Text, e.Authors)
+		e.Text = client.ApplyAdd(add, e.Text)
 		e.Layout.Redraw = true
+		e.countOp()
 	case "delete":
-		var del Delete
-		data := msg.Data.(string)
-		err := json.Unmarshal([]byte(data), &del)
+		var del client.Delete
+		err := client.Decode(msg, &del)
 		if err != nil {
 			break
 		}
@@ -236,9 +311,58 @@ func (e *Editor) handleMessage(msg *ably.Message) {
 				e.View().SetCursor(x-xo-del.Count, y-yo)
 			}
 		}
-		e.Text = applyDel(del, e.Text)
+		e.Authors = client.BlameDel(del, msg.ClientID, e.Text, e.Authors)
+		e.Text = client.ApplyDel(del, e.Text)
 		e.Layout.Redraw = true
+		e.countOp()
+	case "present":
+		e.Gui.Update(func(gui *gocui.Gui) error {
+			e.Layout.handlePresent(msg)
+			return nil
+		})
+	case "snapshot":
+		e.Ops = 0
+	case "meta":
+		e.handleMeta(msg)
+	case "end":
+		e.Gui.Update(func(gui *gocui.Gui) error {
+			e.Layout.handleEnd(msg)
+			return nil
+		})
+	}
+}
+
+// Close sends any changes that haven't been published yet, waiting for them
+// to be acknowledged, stops the editor's goroutines and leaves presence.
+func (e *Editor) Close(ctx context.Context) error {
+	e.EditMux.Lock()
+	e.flushChanges(true)
+	e.EditMux.Unlock()
+
+	close(e.Quit)
+	select {
+	case <-e.Done:
+	case <-ctx.Done():
+		return errors.New("timed out sending changes")
 	}
+
+	return e.Channel.Presence.Leave(ctx, nil)
+}
+
+// countOp counts the ops since the last snapshot, and has the host take a
+// new one every snapshotEvery ops.
+func (e *Editor) countOp() {
+	e.Ops++
+	if e.Ops >= snapshotEvery && e.Layout.isHost() {
+		e.snapshot()
+	}
+}
+
+// snapshot publishes the canonical text, which must be locked.
+func (e *Editor) snapshot() {
+	e.Ops = 0
+	meta := e.Meta
+	e.Queue <- client.MakeSnapshot(e.LastId, e.Text, append([]string(nil), e.Authors...), &meta)
 }
 
 func (e *Editor) View() *gocui.View {
@@ -263,43 +387,15 @@ func (e *Editor) Nodify(s string) {
 }
 
 func (e *Editor) initFromHistory(ctx context.Context) error {
-	_history := e.Channel.History(ably.HistoryWithDirection(ably.Forwards))
-	history, err := _history.Items(ctx)
-	if err != nil {
-		return err
-	}
-
-	ok := history.Next(ctx)
-	if !ok {
-		return errors.New("No file found in session")
-	}
-
-	item := history.Item()
-
-	if item.Name != "new" {
-		return errors.New("No file found in session")
-	}
-
-	e.handleMessage(item)
-
-	for {
-		ok = history.Next(ctx)
-
-		if !ok {
-			break
-		}
-
-		item = history.Item()
-		e.handleMessage(item)
-	}
-
-	return history.Err()
-
+	return client.Replay(ctx, e.Channel, func(msg *ably.Message) {
+		e.Layout.replayHost(msg)
+		e.handleMessage(msg)
+	})
 }
 
 func (e *Editor) flushChanges(cursor bool) {
 	if e.EditBuffer != nil {
-		e.Queue <- e.EditBuffer
+		e.send(e.EditBuffer)
 		e.EditBuffer = nil
 	}
 
@@ -307,7 +403,10 @@ func (e *Editor) flushChanges(cursor bool) {
 	if cursor && err == nil {
 		x, y := v.Cursor()
 		xo, yo := v.Origin()
-		cur := Cursor{X: x + xo, Y: y + yo}
+		cur := client.Cursor{X: x + xo, Y: y + yo, OX: xo, OY: yo}
+		if e.Layout.Mark != nil {
+			cur.Mark, cur.MX, cur.MY = true, e.Layout.Mark.X, e.Layout.Mark.Y
+		}
 		if e.LastCursor != cur {
 			e.LastCursor = cur
 			e.Queue <- &cur
@@ -315,17 +414,109 @@ func (e *Editor) flushChanges(cursor bool) {
 	}
 }
 
+// send publishes ops as one batch, keeping them in Pending so that they are
+// shown until they come back. The editor must be locked.
+func (e *Editor) send(ops ...interface{}) {
+	if len(ops) == 0 {
+		return
+	}
+	e.Pending = append(e.Pending, ops...)
+	e.Version++
+	if len(ops) == 1 {
+		e.Queue <- ops[0]
+	} else {
+		e.Queue <- ops
+	}
+}
+
+// dropPending forgets ops that failed to publish, as they will never come
+// back. The editor must be locked.
+func (e *Editor) dropPending(ops []interface{}) {
+	failed := make(map[interface{}]bool)
+	for _, op := range ops {
+		failed[op] = true
+	}
+	pending := e.Pending[:0]
+	for _, op := range e.Pending {
+		if !failed[op] {
+			pending = append(pending, op)
+		}
+	}
+	e.Pending = pending
+	e.Version++
+	e.Layout.Redraw = true
+}
+
 func (e *Editor) editLoop() {
 	for {
 		select {
+		case <-e.Quit:
+			return
 		case <-time.After(600000 * time.Microsecond):
 			e.EditMux.Lock()
 			e.flushChanges(true)
+			idle := time.Since(e.LastActive) > idleAfter
 			e.EditMux.Unlock()
+			e.UpdatePresence(func(p *client.Presence) { p.Idle = idle })
 		}
 	}
 }
 
+// UpdatePresence applies change to the presence data and sends it if it is
+// different.
+func (e *Editor) UpdatePresence(change func(p *client.Presence)) {
+	e.PresMux.Lock()
+	defer e.PresMux.Unlock()
+	p := e.Presence
+	change(&p)
+	if p == e.Presence {
+		return
+	}
+	e.Presence = p
+
+	// Only the latest presence matters, so replace any that hasn't been sent.
+	select {
+	case <-e.PresQueue:
+	default:
+	}
+	e.PresQueue <- p
+}
+
+// presenceLoop sends presence updates one at a time, so that they arrive in
+// the order they were made.
+func (e *Editor) presenceLoop() {
+	for {
+		select {
+		case <-e.Quit:
+			return
+		case p := <-e.PresQueue:
+			err := e.Channel.Presence.Update(context.Background(), p.Data())
+			if err != nil {
+				e.Nodify(err.Error())
+			}
+		}
+	}
+}
+
+// moveTo puts the cursor at x, y in the document, scrolling the view to the
+// origin xo, yo or as little as possible from it to keep the cursor visible.
+func (e *Editor) moveTo(x, y, xo, yo int) {
+	xs, ys := e.View().Size()
+	if x-xo >= xs {
+		xo = x - xs + 1
+	} else if x < xo {
+		xo = x
+	}
+	if y-yo >= ys {
+		yo = y - ys + 1
+	} else if y < yo {
+		yo = y
+	}
+
+	e.View().SetOrigin(xo, yo)
+	e.View().SetCursor(x-xo, y-yo)
+}
+
 func (e *Editor) cursorPos() (int, int) {
 	ox, oy := e.View().Origin()
 	x, y := e.View().Cursor()
@@ -333,17 +524,19 @@ func (e *Editor) cursorPos() (int, int) {
 }
 
 func (e *Editor) AddChar(ch rune) {
-	add, ok := e.EditBuffer.(*Add)
+	e.Version++
+	add, ok := e.EditBuffer.(*client.Add)
 	if !ok {
 		e.flushChanges(true)
 		x, y := e.cursorPos()
-		e.EditBuffer = &Add{Line: y, Pos: x, Text: string(ch)}
+		e.EditBuffer = &client.Add{Line: y, Pos: x, Text: string(ch)}
 	} else {
 		add.Text += string(ch)
 	}
 }
 func (e *Editor) DelChar(before bool) {
-	del, ok := e.EditBuffer.(*Delete)
+	e.Version++
+	del, ok := e.EditBuffer.(*client.Delete)
 	x, y := e.cursorPos()
 
 	if y < 0 {
@@ -352,7 +545,7 @@ func (e *Editor) DelChar(before bool) {
 
 	if !ok || (x == 0 && before) {
 		e.flushChanges(true)
-		del = &Delete{Line: y, Pos: x, Count: 0}
+		del = &client.Delete{Line: y, Pos: x, Count: 0}
 		e.EditBuffer = del
 	}
 	if x == 0 && before {
@@ -366,9 +559,29 @@ func (e *Editor) DelChar(before bool) {
 	}
 }
 
+// interact notes that the user is doing something in the editor, which stops
+// them following anyone. It returns false, saying why, if they are locked to a
+// presentation and can't. The editor must be locked.
+func (e *Editor) interact() bool {
+	e.LastActive = time.Now()
+	e.Layout.Prefix = ""
+	if e.Layout.locked() {
+		e.Nodify("Following the presentation, " + e.Layout.hint("present", "detach"))
+		return false
+	}
+	if e.Layout.Following != "" {
+		e.Layout.Following = ""
+		e.Nodify("Stopped following")
+	}
+	return true
+}
+
 func (e *Editor) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
 	e.EditMux.Lock()
 	defer e.EditMux.Unlock()
+	if !e.interact() {
+		return
+	}
 	switch {
 	case ch != 0 && mod == 0:
 		e.AddChar(ch)
@@ -377,10 +590,15 @@ func (e *Editor) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier)
 		e.AddChar(' ')
 		v.EditWrite(' ')
 
-		add, ok := e.EditBuffer.(*Add)
+		add, ok := e.EditBuffer.(*client.Add)
 		if ok && add.Text != " " && !strings.HasSuffix(add.Text, "  ") {
 			e.flushChanges(true)
 		}
+	case key == gocui.KeyTab:
+		for _, ch := range e.Meta.IndentText() {
+			e.AddChar(ch)
+			v.EditWrite(ch)
+		}
 	case key == gocui.KeyBackspace || key == gocui.KeyBackspace2:
 		e.DelChar(true)
 		v.EditDelete(true)
@@ -393,32 +611,150 @@ func (e *Editor) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier)
 		x, y := e.cursorPos()
 		v.EditNewLine()
 		e.flushChanges(true)
-		e.EditBuffer = &Add{Line: y, Pos: x, Text: ""}
+		e.EditBuffer = &client.Add{Line: y, Pos: x, Text: ""}
 		e.flushChanges(true)
+	case mod == gocui.ModAlt && (key == gocui.KeyArrowLeft || ch == 'b'):
+		e.wordLeft()
+	case mod == gocui.ModAlt && (key == gocui.KeyArrowRight || ch == 'f'):
+		e.wordRight()
+	case mod == gocui.ModAlt && (key == gocui.KeyHome || ch == '<'):
+		e.docStart()
+	case mod == gocui.ModAlt && (key == gocui.KeyEnd || ch == '>'):
+		e.docEnd()
 	case key == gocui.KeyArrowDown:
-		_, y := e.cursorPos()
-		if y+1 < len(e.Text) {
-			e.flushChanges(false)
-			v.MoveCursor(0, 1, false)
-		} else {
-			_, ry := e.View().Cursor()
-			v.SetCursor(len(e.Text[ry]), ry)
-		}
+		e.lineDown()
 	case key == gocui.KeyArrowUp:
-		e.flushChanges(false)
-		v.MoveCursor(0, -1, false)
+		e.lineUp()
 	case key == gocui.KeyArrowLeft:
-		e.flushChanges(false)
-		v.MoveCursor(-1, 0, false)
+		e.charLeft()
 	case key == gocui.KeyArrowRight:
-		x, y := e.cursorPos()
-		if y+1 < len(e.Text) || x < len(e.Text[y]) {
-			e.flushChanges(false)
-			v.MoveCursor(1, 0, false)
-		}
+		e.charRight()
+	case key == gocui.KeyHome:
+		e.lineStart()
+	case key == gocui.KeyEnd:
+		e.lineEnd()
+	case key == gocui.KeyPgup:
+		e.page(-1)
+	case key == gocui.KeyPgdn:
+		e.page(1)
+	}
+}
+
+// The motions move the cursor through the text as it is shown. The editor
+// must be locked.
+
+func (e *Editor) charLeft() {
+	x, y := e.cursorPos()
+	if x == 0 && y > 0 {
+		e.navigate(1<<30, y-1)
+	} else {
+		e.navigate(x-1, y)
 	}
 }
 
+func (e *Editor) charRight() {
+	x, y := e.cursorPos()
+	text := e.shownText()
+	if y < len(text) && x < len(text[y]) {
+		e.navigate(x+1, y)
+	} else if y+1 < len(text) {
+		e.navigate(0, y+1)
+	}
+}
+
+func (e *Editor) lineUp() {
+	x, y := e.cursorPos()
+	e.navigate(x, y-1)
+}
+
+func (e *Editor) lineDown() {
+	x, y := e.cursorPos()
+	if y+1 < len(e.shownText()) {
+		e.navigate(x, y+1)
+	} else {
+		e.navigate(1<<30, y)
+	}
+}
+
+func (e *Editor) lineStart() {
+	_, y := e.cursorPos()
+	e.navigate(0, y)
+}
+
+func (e *Editor) lineEnd() {
+	_, y := e.cursorPos()
+	e.navigate(1<<30, y)
+}
+
+func (e *Editor) wordLeft() {
+	x, y := e.cursorPos()
+	p := prevWord(e.shownText(), pos{x, y})
+	e.navigate(p.X, p.Y)
+}
+
+func (e *Editor) wordRight() {
+	x, y := e.cursorPos()
+	p := nextWord(e.shownText(), pos{x, y})
+	e.navigate(p.X, p.Y)
+}
+
+func (e *Editor) docStart() {
+	e.navigate(0, 0)
+}
+
+func (e *Editor) docEnd() {
+	text := e.shownText()
+	e.navigate(len(text[len(text)-1]), len(text)-1)
+}
+
+func (e *Editor) pageUp() {
+	e.page(-1)
+}
+
+func (e *Editor) pageDown() {
+	e.page(1)
+}
+
+// navigate flushes the pending edit and moves the cursor to x, y, kept within
+// the text, scrolling no more than needed. The cursor is broadcast by the
+// edit loop, so however many keys are pressed it is sent once.
+func (e *Editor) navigate(x, y int) {
+	text := e.shownText()
+	e.flushChanges(false)
+	if y >= len(text) {
+		y = len(text) - 1
+	}
+	if y < 0 {
+		y = 0
+	}
+	if x > len(text[y]) {
+		x = len(text[y])
+	}
+	if x < 0 {
+		x = 0
+	}
+	xo, yo := e.View().Origin()
+	e.moveTo(x, y, xo, yo)
+}
+
+// page scrolls the view a page up or down, moving the cursor with it.
+func (e *Editor) page(dir int) {
+	_, ys := e.View().Size()
+	xo, yo := e.View().Origin()
+	x, y := e.cursorPos()
+	lines := len(e.shownText())
+
+	yo += dir * ys
+	if yo > lines-ys {
+		yo = lines - ys
+	}
+	if yo < 0 {
+		yo = 0
+	}
+	e.View().SetOrigin(xo, yo)
+	e.navigate(x, y+dir*ys)
+}
+
 func (e *Editor) dupText() [][]byte {
 	text := make([][]byte, len(e.Text))
 
@@ -429,16 +765,29 @@ func (e *Editor) dupText() [][]byte {
 	return text
 }
 
+// shownText returns the text as it is shown, with the ops that haven't come
+// back yet and the edit being typed applied.
+func (e *Editor) shownText() [][]byte {
+	text := e.dupText()
+	apply := func(op interface{}) {
+		switch op := op.(type) {
+		case *client.Add:
+			text = client.ApplyAdd(*op, text)
+		case *client.Delete:
+			text = client.ApplyDel(*op, text)
+		}
+	}
+	for _, op := range e.Pending {
+		apply(op)
+	}
+	apply(e.EditBuffer)
+	return text
+}
+
 func (e *Editor) displyText() {
 	e.View().Clear()
 
-	text := e.dupText()
-	switch msg := e.EditBuffer.(type) {
-	case *Add:
-		text = applyAdd(*msg, text)
-	case *Delete:
-		text = applyDel(*msg, text)
-	}
+	text := e.shownText()
 
 	// Hack for bug in gocui
 	if len(e.Text[0]) == 0 {
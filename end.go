@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// endSession asks the host to confirm, then ends the session for everyone.
+func (l *Layout) endSession(gui *gocui.Gui, v *gocui.View) error {
+	if !l.isHost() {
+		l.Editor.Nodify("Only the host can end the session")
+		return nil
+	}
+	if l.Ended {
+		return nil
+	}
+
+	l.ask(&Prompt{
+		Editor: l.Editor,
+		Label:  "End the session for everyone? (y/n)",
+		OnEnter: func(value string) error {
+			switch value {
+			case "y", "yes":
+				l.Editor.EditMux.Lock()
+				l.Editor.flushChanges(true)
+				l.Editor.EditMux.Unlock()
+				l.Editor.Queue <- &client.End{}
+			case "n", "no", "":
+			default:
+				return errors.New("Type y to end the session or n to carry on")
+			}
+			return nil
+		},
+	})
+	return nil
+}
+
+// handleEnd makes the editor read-only and offers to save a copy of the
+// document. Pending edits are dropped as nobody would receive them. It must be
+// called on the UI goroutine.
+func (l *Layout) handleEnd(msg *ably.Message) {
+	if l.Ended {
+		return
+	}
+	l.Ended = true
+	l.Editable = false
+	l.Editor.EditMux.Lock()
+	l.Editor.EditBuffer = nil
+	l.Editor.EditMux.Unlock()
+	l.Redraw = true
+
+	if msg.ClientID == l.Id {
+		l.Editor.Nodify("You ended the session")
+	} else {
+		l.Editor.Nodify(l.authorName(msg.ClientID) + " ended the session, the document is read only")
+	}
+	if l.Player == nil {
+		l.Save = &Save{Editor: l.Editor, Force: true}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+)
+
+// Edits by the same member less than this far apart are shown as one entry
+// in the timeline.
+const timelineGap = time.Minute
+
+// The colours used by the terminal UI: blue, cyan, green, magenta and red.
+var exportColours = []string{"#1e66f5", "#04a5e5", "#40a02b", "#8839ef", "#d20f39"}
+
+type exportMember struct {
+	Id     string
+	Name   string
+	Colour string
+}
+
+type exportLine struct {
+	Number int
+	Author *exportMember
+	Text   string
+}
+
+type exportEdit struct {
+	Author *exportMember
+	Start  time.Time
+	End    time.Time
+	Count  int
+}
+
+type exportDoc struct {
+	Code     string
+	Exported time.Time
+	Members  []*exportMember
+	Lines    []exportLine
+	Timeline []exportEdit
+	// Width is the length of the longest author name in Lines.
+	Width int
+	// Fence is a Markdown code fence longer than any run of backticks in
+	// Lines.
+	Fence string
+}
+
+func export(ctx context.Context, channel *ably.RealtimeChannel, args *Arguments) error {
+	doc := exportDoc{Code: args.Arg, Exported: time.Now()}
+	members := make(map[string]*exportMember)
+
+	member := func(id string) *exportMember {
+		m, ok := members[id]
+		if !ok {
+			m = &exportMember{Id: id, Name: id, Colour: exportColours[colourIndex(id, client.Presence{})]}
+			members[id] = m
+			doc.Members = append(doc.Members, m)
+		}
+		return m
+	}
+
+	text, authors, err := replayBlame(ctx, channel, func(msg *ably.Message) {
+		switch msg.Name {
+		case "new", "add", "delete":
+		default:
+			return
+		}
+
+		author := member(msg.ClientID)
+		at := time.UnixMilli(msg.Timestamp)
+		last := len(doc.Timeline) - 1
+		if last >= 0 && doc.Timeline[last].Author == author && at.Sub(doc.Timeline[last].End) < timelineGap {
+			doc.Timeline[last].End = at
+			doc.Timeline[last].Count++
+		} else {
+			doc.Timeline = append(doc.Timeline, exportEdit{Author: author, Start: at, End: at, Count: 1})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	present, err := sessionMembers(ctx, channel)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(present))
+	for id := range present {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		m := member(id)
+		m.Name = present[id].Name
+		m.Colour = exportColours[colourIndex(id, present[id])]
+	}
+
+	for i, line := range text {
+		l := exportLine{Number: i + 1, Text: strings.TrimRight(string(line), "\r")}
+		if i < len(authors) && authors[i] != "" {
+			l.Author = member(authors[i])
+		}
+		doc.Lines = append(doc.Lines, l)
+		if l.Author != nil && len(l.Author.Name) > doc.Width {
+			doc.Width = len(l.Author.Name)
+		}
+	}
+	doc.Fence = fence(doc.Lines)
+
+	if !args.Timeline {
+		doc.Timeline = nil
+	}
+
+	var out io.Writer = os.Stdout
+	if args.Out != "" {
+		file, err := os.Create(args.Out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if args.Format == "md" {
+		return markdownExport.Execute(out, doc)
+	}
+	return htmlExport.Execute(out, doc)
+}
+
+var exportFuncs = map[string]interface{}{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"name": func(m *exportMember) string {
+		if m == nil {
+			return ""
+		}
+		return m.Name
+	},
+	"pad": func(s string, width int) string {
+		if len(s) >= width {
+			return s
+		}
+		return s + strings.Repeat(" ", width-len(s))
+	},
+}
+
+// fence returns a run of backticks long enough to fence lines in Markdown,
+// which is at least three and longer than any run within them.
+func fence(lines []exportLine) string {
+	longest := 2
+	for _, line := range lines {
+		run := 0
+		for _, c := range line.Text {
+			if c != '`' {
+				run = 0
+				continue
+			}
+			run++
+			if run > longest {
+				longest = run
+			}
+		}
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+var htmlExport = htmltemplate.Must(htmltemplate.New("html").Funcs(exportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sync-edit session {{.Code}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table.doc { border-collapse: collapse; font-family: monospace; white-space: pre; }
+table.doc td { padding: 0 0.5em; }
+td.number { color: #888; text-align: right; user-select: none; }
+td.author { color: #888; user-select: none; }
+.swatch { display: inline-block; width: 1em; height: 1em; vertical-align: middle; margin-right: 0.3em; }
+</style>
+</head>
+<body>
+<h1>Session {{.Code}}</h1>
+<p>Exported {{time .Exported}}</p>
+<h2>Members</h2>
+<ul>
+{{- range .Members}}
+<li><span class="swatch" style="background: {{.Colour}}"></span>{{.Name}}</li>
+{{- end}}
+</ul>
+<h2>Document</h2>
+<table class="doc">
+{{- range .Lines}}
+<tr{{if .Author}} style="background: {{.Author.Colour}}22"{{end}}><td class="number">{{.Number}}</td><td class="author"{{if .Author}} style="border-left: 4px solid {{.Author.Colour}}">{{.Author.Name}}{{else}}>{{end}}</td><td>{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- if .Timeline}}
+<h2>Timeline</h2>
+<ul>
+{{- range .Timeline}}
+<li>{{time .Start}} <span class="swatch" style="background: {{.Author.Colour}}"></span>{{.Author.Name}}: {{.Count}} edit{{if ne .Count 1}}s{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+var markdownExport = template.Must(template.New("md").Funcs(exportFuncs).Parse(`# Session {{.Code}}
+
+Exported {{time .Exported}}
+
+## Members
+{{range .Members}}
+- {{.Name}}
+{{- end}}
+
+## Document
+
+{{.Fence}}
+{{- $width := .Width}}
+{{- range .Lines}}
+{{pad (name .Author) $width}} | {{.Text}}
+{{- end}}
+{{.Fence}}
+{{- if .Timeline}}
+
+## Timeline
+{{range .Timeline}}
+- {{time .Start}} {{.Author.Name}}: {{.Count}} edit{{if ne .Count 1}}s{{end}}
+{{- end}}
+{{- end}}
+`))
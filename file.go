@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var errChangedOnDisk = errors.New("the file has changed on disk")
+
+// writeFile replaces name with data atomically, by writing to a temporary file
+// in the same directory and renaming it over the original, keeping the
+// original's mode and owner. If name is a symlink the file it points to is
+// replaced instead. If backup is set the original is kept as name~.
+func writeFile(name string, data []byte, backup bool) error {
+	resolved, err := filepath.EvalSymlinks(name)
+	if err == nil {
+		name = resolved
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	mode := fs.FileMode(0644)
+	info, err := os.Stat(name)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		chown(tmp.Name(), info)
+	}
+
+	if backup && info != nil {
+		err = backupFile(name, mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// backupFile keeps a copy of name as name~ with the given mode. It is a copy
+// rather than a link, so that nothing done to one can change the other.
+func backupFile(name string, mode fs.FileMode) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(name + "~")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(name+"~", data, mode)
+}
+
+// changedOnDisk reports whether name has changed since this client last read
+// or wrote it.
+func (e *Editor) changedOnDisk(name string) bool {
+	if name != e.DiskFile {
+		return false
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return false
+	}
+	return sha256.Sum256(data) != e.DiskHash
+}
+
+func (e *Editor) onDisk(name string, data []byte) {
+	e.DiskFile = name
+	e.DiskHash = sha256.Sum256(data)
+}
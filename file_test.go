@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// onlyFiles checks that dir holds just the given files, so nothing temporary
+// was left behind.
+func onlyFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got files %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got files %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWriteFileNew(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	err := writeFile(name, []byte("hello"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name); got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("got mode %v, want %v", info.Mode().Perm(), os.FileMode(0644))
+	}
+	onlyFiles(t, dir, "a.txt")
+}
+
+func TestWriteFileReplaces(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("old"), 0600)
+	os.Chmod(name, 0751)
+	before, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeFile(name, []byte("new"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name); got != "new" {
+		t.Errorf("got %q, want %q", got, "new")
+	}
+	after, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Mode().Perm() != 0751 {
+		t.Errorf("got mode %v, want the original %v", after.Mode().Perm(), os.FileMode(0751))
+	}
+	if os.SameFile(before, after) {
+		t.Error("the file was written in place rather than replaced")
+	}
+	onlyFiles(t, dir, "a.txt")
+}
+
+func TestWriteFileBackup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("first"), 0600)
+	os.WriteFile(name+"~", []byte("older"), 0644)
+
+	err := writeFile(name, []byte("second"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name); got != "second" {
+		t.Errorf("got %q, want %q", got, "second")
+	}
+	if got := readFile(t, name+"~"); got != "first" {
+		t.Errorf("got backup %q, want %q", got, "first")
+	}
+	info, err := os.Stat(name + "~")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got backup mode %v, want the original's %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+
+	// The backup is a copy rather than a link, so it keeps what it had.
+	err = writeFile(name, []byte("third"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name+"~"); got != "first" {
+		t.Errorf("got backup %q after writing without one, want %q", got, "first")
+	}
+	onlyFiles(t, dir, "a.txt", "a.txt~")
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	os.WriteFile(target, []byte("old"), 0640)
+	err := os.Symlink("target.txt", link)
+	if err != nil {
+		t.Skip("can't make symlinks here:", err)
+	}
+
+	err = writeFile(link, []byte("new"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("the link was replaced by a file")
+	}
+	if got := readFile(t, target); got != "new" {
+		t.Errorf("got %q in the target, want %q", got, "new")
+	}
+	if got := readFile(t, target+"~"); got != "old" {
+		t.Errorf("got backup %q, want %q", got, "old")
+	}
+	info, err = os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("got mode %v, want the target's %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	onlyFiles(t, dir, "link.txt", "target.txt", "target.txt~")
+}
+
+func TestWriteFileMissingDir(t *testing.T) {
+	dir := t.TempDir()
+	err := writeFile(filepath.Join(dir, "missing", "a.txt"), []byte("x"), false)
+	if err == nil {
+		t.Error("got no error writing into a directory that doesn't exist")
+	}
+	onlyFiles(t, dir)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// Each member takes up two lines of the members pane.
+const memberLines = 2
+
+func (l *Layout) pickMember(gui *gocui.Gui, v *gocui.View) error {
+	members, err := gui.View("members")
+	if err != nil {
+		return err
+	}
+	l.Picking = true
+	members.SetOrigin(0, 0)
+	members.SetCursor(0, 0)
+	return nil
+}
+
+func (l *Layout) layoutPicker(gui *gocui.Gui) error {
+	members, err := gui.SetCurrentView("members")
+	if err != nil {
+		return err
+	}
+	members.Highlight = true
+	members.SelBgColor = gocui.ColorWhite
+	members.SelFgColor = gocui.ColorBlack
+	members.Title = "Enter Follow"
+	if l.isHost() {
+		members.Title += " h Host"
+	}
+	return nil
+}
+
+func (l *Layout) closePicker(gui *gocui.Gui, v *gocui.View) error {
+	l.Picking = false
+	v.Highlight = false
+	v.Title = ""
+	return nil
+}
+
+func (l *Layout) pickerMove(d int) func(gui *gocui.Gui, v *gocui.View) error {
+	return func(gui *gocui.Gui, v *gocui.View) error {
+		_, y := v.Cursor()
+		y += d * memberLines
+		if y >= 0 && y/memberLines < len(l.Members) {
+			v.SetCursor(0, y)
+		}
+		return nil
+	}
+}
+
+// picked returns the member selected in the members pane.
+func (l *Layout) picked(v *gocui.View) *ably.PresenceMessage {
+	_, y := v.Cursor()
+	_, yo := v.Origin()
+	i := (y + yo) / memberLines
+	if i < 0 || i >= len(l.Members) {
+		return nil
+	}
+	return l.Members[i]
+}
+
+func (l *Layout) follow(gui *gocui.Gui, v *gocui.View) error {
+	member := l.picked(v)
+	l.closePicker(gui, v)
+	if member == nil {
+		return nil
+	}
+	if member.ClientID == l.Id {
+		l.Editor.Nodify("You can't follow yourself")
+		return nil
+	}
+
+	l.Following = member.ClientID
+	l.Editor.Nodify("Following " + memberName(member) + ", type or move to stop")
+	l.followCursor(l.Following)
+	return nil
+}
+
+// leader returns the member whose view the editor is tracking, either
+// because they are presenting or they are being followed.
+func (l *Layout) leader() string {
+	if l.locked() {
+		return l.Presenter
+	}
+	return l.Following
+}
+
+// followCursor scrolls the editor to match the view of the given member,
+// making sure their cursor is visible, and moves the local cursor to theirs.
+func (l *Layout) followCursor(id string) {
+	pos, ok := l.Cursors[id]
+	if id == "" || !ok {
+		return
+	}
+
+	l.Editor.moveTo(pos.X, pos.Y, pos.OX, pos.OY)
+}
@@ -1,33 +1,102 @@
 package main
 
 import (
-	"context"
 	"fmt"
+	"hash/fnv"
+	"path/filepath"
 	"strings"
 
+	"github.com/ably-labs/sync-edit/client"
 	"github.com/ably/ably-go/ably"
 	"github.com/jroimartin/gocui"
 )
 
 var colours []gocui.Attribute = []gocui.Attribute{gocui.ColorBlue, gocui.ColorCyan, gocui.ColorGreen, gocui.ColorMagenta, gocui.ColorRed}
+var colourNames []string = []string{"blue", "cyan", "green", "magenta", "red"}
 
 type Layout struct {
-	Id       string
-	FileName string
-	Log      bool
-	Editable bool
-	Setup    bool
-	Save     *Save
-	Redraw   bool
-	Editor   *Editor
-	Code     string
-	Members  []*ably.PresenceMessage
-	Cursors  map[string]Cursor
+	Id        string
+	FileName  string
+	Log       bool
+	Editable  bool
+	Setup     bool
+	Save      *Save
+	Prompt    *Prompt
+	Prompts   []*Prompt
+	History   *HistoryView
+	Redraw    bool
+	Editor    *Editor
+	Player    *Player
+	Recorder  *Recorder
+	Blame     bool
+	Picking   bool
+	Following string
+	Presenter string
+	Detached  bool
+	Mark      *client.Cursor
+	Jumped    string
+	Host      string
+	Ended     bool
+	Config    *Config
+	Resets    map[string]*resetVote
+	Opens     map[string]opening
+	Allowed   map[string]bool
+	Search    *Search
+	Command   *CommandLine
+	Keymap    Keymap
+	Prefix    string
+	Vim       *Vim
+	Names     map[string]string
+	Code      string
+	Members   []*ably.PresenceMessage
+	Cursors   map[string]client.Cursor
 }
 
-type Cursor struct {
-	X int `json:"x"`
-	Y int `json:"y"`
+// memberName returns the display name a member entered presence with.
+func memberName(member *ably.PresenceMessage) string {
+	return client.DecodePresence(member).Name
+}
+
+// memberDetail returns a short description of a member's role, state and the
+// file they have open.
+func memberDetail(p client.Presence) string {
+	var detail []string
+	if p.Role != "" && p.Role != "member" {
+		detail = append(detail, p.Role)
+	}
+	if p.Idle {
+		detail = append(detail, "idle")
+	}
+	if p.File != "" {
+		detail = append(detail, filepath.Base(p.File))
+	}
+	return strings.Join(detail, " ")
+}
+
+// colourIndex returns the index in colours of the colour a member asked for
+// in their presence data, or else one picked from their ClientID so that it
+// is the same for everyone and doesn't change as people come and go.
+func colourIndex(id string, p client.Presence) int {
+	for i, name := range colourNames {
+		if p.Colour == name {
+			return i
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return int(hash.Sum32() % uint32(len(colours)))
+}
+
+// memberColour returns the colour used for the member with the given
+// ClientID, whether or not they are still present.
+func (l *Layout) memberColour(id string) gocui.Attribute {
+	var p client.Presence
+	for _, member := range l.Members {
+		if member.ClientID == id {
+			p = client.DecodePresence(member)
+		}
+	}
+	return colours[colourIndex(id, p)]
 }
 
 func updateBar(gui *gocui.Gui, code string, users int) {
@@ -53,6 +122,9 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 	//gui.Mouse = true
 	gui.Cursor = true
 	maxX, maxY := gui.Size()
+	if l.Keymap == nil {
+		l.Keymap, _ = makeKeymap("", nil)
+	}
 
 	log, err = gui.SetView("log", maxX/2, maxY/2, maxX-1, maxY-3)
 	if err != nil {
@@ -62,7 +134,12 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 		log.Autoscroll = true
 	}
 
-	editor, err = gui.SetView("editor", 0, 0, maxX-21, maxY-3)
+	left := 0
+	if l.Blame {
+		left = blameWidth + 1
+	}
+
+	editor, err = gui.SetView("editor", left, 0, maxX-21, maxY-3)
 	if err != nil {
 		if err != gocui.ErrUnknownView {
 			return err
@@ -72,11 +149,19 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 	}
 	if l.FileName != "" {
 		editor.Title = l.FileName
+	} else if l.Editor != nil && l.Editor.Meta.File != "" {
+		editor.Title = l.Editor.Meta.File
 	} else {
 		editor.Title = "Unsaved"
 	}
-	editor.Editable = l.Editable
+	if l.Editor != nil && l.Editor.dirty() {
+		editor.Title += " *"
+	}
+	editor.Editable = l.Editable && l.Player == nil && !l.Ended
 	editor.Editor = l.Editor
+	if l.Vim != nil {
+		editor.Editor = l.Vim
+	}
 
 	_, err = gui.SetCurrentView("editor")
 	if err != nil {
@@ -97,7 +182,11 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 			return err
 		}
 		keys.Frame = false
-		fmt.Fprint(keys, "C-x Exit  C-n New  C-s Save  C-a Save As")
+		if l.Player != nil {
+			fmt.Fprint(keys, l.Keymap.keysFor("quit")+" Exit  Spc Pause  ←→ Step  [] Seek")
+		} else {
+			fmt.Fprint(keys, l.Keymap.help(41))
+		}
 	}
 
 	members, err = gui.SetView("members", maxX-20, 0, maxX-1, maxY-3)
@@ -108,9 +197,14 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 		members.Frame = true
 	}
 	members.Clear()
-	for i, user := range l.Members {
-		col := colours[i%len(colours)]
-		fmt.Fprintf(members, "\x1b[0;%dm%s\n", col+29, user.Message.Data.(string))
+	if l.Names == nil {
+		l.Names = make(map[string]string)
+	}
+	for _, user := range l.Members {
+		p := client.DecodePresence(user)
+		l.Names[user.ClientID] = p.Name
+		fmt.Fprintf(members, "\x1b[0;%dm%s\n", l.memberColour(user.ClientID)+29, p.Name)
+		fmt.Fprintf(members, "\x1b[0m  %s\n", memberDetail(p))
 	}
 
 	bar, err = gui.SetView("bar", 0, maxY-2, maxX-42, maxY)
@@ -121,105 +215,76 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 		bar.Frame = false
 	}
 	bar.Clear()
-	fmt.Fprintf(bar, "Users: %d Session: %s", len(l.Members), l.Code)
-
-	if !l.Setup {
-		l.Setup = true
-		err = gui.SetKeybinding("", gocui.KeyCtrlX, gocui.ModNone, quit)
-		if err != nil {
-			return err
+	if l.Player != nil {
+		fmt.Fprint(bar, l.Player.Status())
+	} else {
+		fmt.Fprintf(bar, "Users: %d Session: %s", len(l.Members), l.Code)
+		if l.Ended {
+			fmt.Fprint(bar, " (ended)")
+		}
+		if l.Host != "" {
+			fmt.Fprintf(bar, " Host: %s", l.authorName(l.Host))
 		}
-		err = gui.SetKeybinding("", gocui.KeyCtrlL, gocui.ModNone, func(gui *gocui.Gui, v *gocui.View) error {
-			if l.Log {
-				gui.SetViewOnBottom("log")
-			} else {
-				gui.SetViewOnTop("log")
+		if l.Following != "" {
+			fmt.Fprintf(bar, " Following: %s", l.authorName(l.Following))
+		}
+		if l.Presenter != "" {
+			fmt.Fprintf(bar, " Presenting: %s", l.authorName(l.Presenter))
+			if l.Detached {
+				fmt.Fprint(bar, " (detached)")
 			}
-			l.Log = !l.Log
-			return nil
-		})
-		if err != nil {
-			return err
 		}
-		err = gui.SetKeybinding("editor", gocui.KeyCtrlA, gocui.ModNone, func(gui *gocui.Gui, v *gocui.View) error {
-			l.Save = &Save{Editor: l.Editor, Force: true}
-			return nil
-		})
-		if err != nil {
-			return err
+		if l.Vim != nil {
+			fmt.Fprintf(bar, " -- %s --", vimModes[l.Vim.Mode])
 		}
-		err = gui.SetKeybinding("editor", gocui.KeyCtrlS, gocui.ModNone, func(gui *gocui.Gui, v *gocui.View) error {
-			l.Save = &Save{Editor: l.Editor}
-			return nil
-		})
+	}
+
+	if !l.Setup {
+		l.Setup = true
+		err = l.setup(gui)
 		if err != nil {
 			return err
 		}
-		err = gui.SetKeybinding("editor", gocui.KeyCtrlN, gocui.ModNone, func(gui *gocui.Gui, v *gocui.View) error {
-			err = l.Editor.Channel.Publish(context.Background(), "new", "")
-			if err == nil {
-				editor.Editable = false
-			} else {
-				l.Editor.Nodify(err.Error())
-			}
+	}
 
-			return nil
-		})
-	}
-
-	for _, view := range gui.Views() {
-		name := view.Name()
-		if strings.HasPrefix(name, "cursor-") {
-			found := false
-			for _, member := range l.Members {
-				if name == "cursor-"+member.ClientID {
-					found = true
-					break
-				}
-				if !found {
-					gui.DeleteView(name)
-				}
-			}
-		}
+	// run lays the views out once before the editor exists, so that it has
+	// somewhere to show the document. There is nothing more to show yet.
+	if l.Editor == nil {
+		return nil
 	}
 
-	for i, member := range l.Members {
-		if member.ClientID == l.Id {
-			continue
-		}
+	err = l.layoutCursors(gui, editor)
+	if err != nil {
+		return err
+	}
 
-		pos, ok := l.Cursors[member.ClientID]
-		if !ok {
-			continue
-		}
+	err = l.layoutSelection(gui, editor)
+	if err != nil {
+		return err
+	}
 
-		xs, ys := editor.Size()
-		xo, yo := editor.Origin()
-		x := pos.X - xo + 1
-		y := pos.Y - yo + 1
+	err = l.layoutEdges(gui, editor)
+	if err != nil {
+		return err
+	}
 
-		if x < 1 || x > xs+1 || y < 1 || y > ys+1 {
-			gui.DeleteView("cursor-" + member.ClientID)
-		} else {
-			view, err := gui.SetView("cursor-"+member.ClientID, x-1, y-1, x+1, y+1)
-			if err != nil {
-				if err != gocui.ErrUnknownView {
-					return err
-				}
-				view.Frame = false
-			}
-			view.BgColor = colours[i%len(colours)]
-			view.Clear()
-			lines := editor.BufferLines()
-			if len(lines) > pos.Y && len(lines[pos.Y]) > pos.X {
-				view.Write([]byte{lines[pos.Y][pos.X]})
-			}
-		}
+	err = l.layoutMinimap(gui, editor)
+	if err != nil {
+		return err
 	}
 
 	if l.Redraw {
 		l.Redraw = false
+		l.Editor.EditMux.Lock()
 		l.Editor.displyText()
+		l.Editor.EditMux.Unlock()
+	}
+
+	if l.Blame {
+		err = l.layoutBlame(gui, editor)
+		if err != nil {
+			return err
+		}
 	}
 
 	if l.Save != nil {
@@ -230,6 +295,47 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 			}
 		} else {
 			l.Save.Save()
+			l.Save = nil
+		}
+	}
+
+	if l.Picking {
+		err = l.layoutPicker(gui)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.Search != nil {
+		err = l.Search.Layout(gui)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.Command != nil {
+		err = l.Command.Layout(gui)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = l.layoutMatches(gui, editor)
+	if err != nil {
+		return err
+	}
+
+	if l.History != nil {
+		err = l.History.Layout(gui)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.Prompt != nil {
+		err = l.Prompt.Layout(gui)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -238,6 +344,58 @@ func (l *Layout) Layout(gui *gocui.Gui) error {
 	return nil
 }
 
-func quit(g *gocui.Gui, v *gocui.View) error {
-	return gocui.ErrQuit
+func (l *Layout) toggleLog(gui *gocui.Gui, v *gocui.View) error {
+	if l.Log {
+		gui.SetViewOnBottom("log")
+	} else {
+		gui.SetViewOnTop("log")
+	}
+	l.Log = !l.Log
+	return nil
+}
+
+func (l *Layout) setup(gui *gocui.Gui) error {
+	err := l.bindKeys(gui)
+	if err != nil {
+		return err
+	}
+	if l.Player != nil {
+		return l.Player.Bind(gui)
+	}
+
+	// Esc closes these views, as do the keys that opened them, which the
+	// keymap's handler takes care of.
+	err = gui.SetKeybinding("members", gocui.KeyEsc, gocui.ModNone, l.closePicker)
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("members", gocui.KeyArrowUp, gocui.ModNone, l.pickerMove(-1))
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("members", gocui.KeyArrowDown, gocui.ModNone, l.pickerMove(1))
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("members", gocui.KeyEnter, gocui.ModNone, l.follow)
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("members", 'h', gocui.ModNone, l.handOver)
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("history", gocui.KeyEsc, gocui.ModNone, l.closeHistory)
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("history", gocui.KeyArrowUp, gocui.ModNone, scrollView(-1))
+	if err != nil {
+		return err
+	}
+	err = gui.SetKeybinding("history", gocui.KeyArrowDown, gocui.ModNone, scrollView(1))
+	if err != nil {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/jroimartin/gocui"
+)
+
+// newTestGui returns a Gui of the given size that views can be laid out in
+// without a terminal. gocui only learns its size from termbox, so it is set by
+// hand.
+func newTestGui(width, height int) *gocui.Gui {
+	gui := &gocui.Gui{}
+	v := reflect.ValueOf(gui).Elem()
+	for name, size := range map[string]int{"maxX": width, "maxY": height} {
+		field := v.FieldByName(name)
+		reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().SetInt(int64(size))
+	}
+	return gui
+}
+
+func TestLayoutBeforeEditor(t *testing.T) {
+	for _, fileName := range []string{"", "notes.txt"} {
+		layout := &Layout{Id: "self", Code: "code", FileName: fileName, Cursors: make(map[string]client.Cursor)}
+		err := layout.Layout(newTestGui(80, 24))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/jroimartin/gocui"
+)
+
+// HistoryView is a read-only pane showing the document as it was at some
+// point in the session's history.
+type HistoryView struct {
+	At    client.Point
+	Text  [][]byte
+	Drawn bool
+}
+
+func (h *HistoryView) Layout(gui *gocui.Gui) error {
+	maxX, maxY := gui.Size()
+
+	view, err := gui.SetView("history", maxX/2, 0, maxX-21, maxY-3)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		view.Title = fmt.Sprintf("At %s (read only)", h.At)
+	}
+
+	if !h.Drawn {
+		h.Drawn = true
+		view.Clear()
+		for _, line := range h.Text {
+			view.Write(line)
+			view.Write([]byte{'\n'})
+		}
+	}
+
+	gui.SetCurrentView("history")
+	return nil
+}
+
+func (l *Layout) showHistory(gui *gocui.Gui, v *gocui.View) error {
+	l.ask(&Prompt{
+		Editor: l.Editor,
+		Label:  "Show document at (time or message id):",
+		OnEnter: func(value string) error {
+			at, err := client.ParsePoint(value)
+			if err != nil {
+				return err
+			}
+			l.Editor.Nodify("Loading " + at.String())
+			go func() {
+				text, err := client.TextAt(context.Background(), l.Editor.Channel, at)
+				gui.Update(func(gui *gocui.Gui) error {
+					if err != nil {
+						l.Editor.Nodify(err.Error())
+						return nil
+					}
+					l.Editor.Nodify("")
+					gui.DeleteView("history")
+					l.History = &HistoryView{At: at, Text: text}
+					return nil
+				})
+			}()
+			return nil
+		},
+	})
+	return nil
+}
+
+func (l *Layout) closeHistory(gui *gocui.Gui, v *gocui.View) error {
+	l.History = nil
+	return gui.DeleteView("history")
+}
+
+func scrollView(dy int) func(gui *gocui.Gui, v *gocui.View) error {
+	return func(gui *gocui.Gui, v *gocui.View) error {
+		xo, yo := v.Origin()
+		if yo+dy >= 0 {
+			v.SetOrigin(xo, yo+dy)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+func (l *Layout) isHost() bool {
+	return l.Host != "" && l.Host == l.Id
+}
+
+// electHost chooses who takes over from a host who left: the member with the
+// lowest ClientID, other than bots, so that everyone makes the same choice.
+// Members can claim any role in presence, so claiming to be the host counts
+// for nothing.
+func electHost(members []*ably.PresenceMessage) string {
+	host := ""
+	for _, member := range members {
+		if client.DecodePresence(member).Role != "bot" && (host == "" || member.ClientID < host) {
+			host = member.ClientID
+		}
+	}
+	return host
+}
+
+// updateHost keeps the host for as long as they are present. Once they have
+// left, it elects another, and if that is this client it takes over and says
+// so in the channel, so that members who join later know who the host is.
+func (l *Layout) updateHost() {
+	if l.Host != "" && l.present(l.Host) {
+		return
+	}
+	l.Host = electHost(l.Members)
+	if l.Host == l.Id {
+		l.Editor.Queue <- &client.Handover{Client: l.Id}
+		l.becomeHost("The host left, you are now the host")
+	}
+}
+
+// replayHost follows who the host was through the history a joining client
+// replays. The document was last replaced by the host, and after that only
+// the host can hand over, or a member who took over from a host who has since
+// left.
+func (l *Layout) replayHost(msg *ably.Message) {
+	switch msg.Name {
+	case "new":
+		l.Host = msg.ClientID
+	case "host":
+		var handover client.Handover
+		if client.Decode(msg, &handover) != nil {
+			return
+		}
+		if msg.ClientID == l.Host || handover.Client == msg.ClientID && !l.present(l.Host) {
+			l.Host = handover.Client
+		}
+	}
+}
+
+func (l *Layout) becomeHost(reason string) {
+	l.Editor.UpdatePresence(func(p *client.Presence) { p.Role = "host" })
+	l.Editor.Nodify(reason)
+}
+
+// handOver makes the member selected in the members pane the host.
+func (l *Layout) handOver(gui *gocui.Gui, v *gocui.View) error {
+	member := l.picked(v)
+	l.closePicker(gui, v)
+	if member == nil {
+		return nil
+	}
+	if !l.isHost() {
+		l.Editor.Nodify("Only the host can hand over")
+		return nil
+	}
+	if member.ClientID == l.Id {
+		return nil
+	}
+	if client.DecodePresence(member).Role == "bot" {
+		l.Editor.Nodify("Bots can't be the host")
+		return nil
+	}
+
+	l.Editor.Queue <- &client.Handover{Client: member.ClientID}
+	return nil
+}
+
+// handleHandover makes a member the host when the host hands over to them.
+// A member taking over from a host who left is already the host here, as
+// everyone elects the same one.
+func (l *Layout) handleHandover(msg *ably.Message) {
+	var handover client.Handover
+	err := client.Decode(msg, &handover)
+	if err != nil || msg.ClientID != l.Host || handover.Client == l.Host {
+		return
+	}
+
+	l.Host = handover.Client
+	if handover.Client == l.Id {
+		l.becomeHost(l.authorName(msg.ClientID) + " made you the host")
+	} else if msg.ClientID == l.Id {
+		l.Editor.UpdatePresence(func(p *client.Presence) { p.Role = "member" })
+		l.Editor.Nodify(l.authorName(handover.Client) + " is now the host")
+	}
+}
+
+// takeSnapshot publishes a snapshot of the document straight away.
+func (l *Layout) takeSnapshot(gui *gocui.Gui, v *gocui.View) error {
+	if !l.isHost() {
+		l.Editor.Nodify("Only the host can take snapshots")
+		return nil
+	}
+	l.Editor.EditMux.Lock()
+	l.Editor.snapshot()
+	l.Editor.EditMux.Unlock()
+	l.Editor.Nodify("Snapshot taken")
+	return nil
+}
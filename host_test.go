@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+)
+
+func member(id, role string) *ably.PresenceMessage {
+	p := client.Presence{Name: id, Role: role}
+	return &ably.PresenceMessage{Message: ably.Message{ClientID: id, Data: p.Data()}}
+}
+
+func TestElectHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []*ably.PresenceMessage
+		want    string
+	}{
+		{"nobody", nil, ""},
+		{"lowest id", []*ably.PresenceMessage{member("carol", "member"), member("alice", "member"), member("bob", "member")}, "alice"},
+		{"claiming to be host counts for nothing", []*ably.PresenceMessage{member("bob", "host"), member("alice", "member")}, "alice"},
+		{"not a bot", []*ably.PresenceMessage{member("alice", "bot"), member("bob", "member")}, "bob"},
+		{"only bots", []*ably.PresenceMessage{member("alice", "bot")}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := electHost(test.members); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		members []*ably.PresenceMessage
+		want    string
+	}{
+		{"host stays while present", "carol", []*ably.PresenceMessage{member("alice", "member"), member("carol", "member")}, "carol"},
+		{"host stays when others claim the role", "carol", []*ably.PresenceMessage{member("alice", "host"), member("carol", "host")}, "carol"},
+		{"host left", "carol", []*ably.PresenceMessage{member("bob", "member"), member("alice", "host")}, "alice"},
+		{"no host yet", "", []*ably.PresenceMessage{member("bob", "member")}, "bob"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Layout{Id: "zed", Host: test.host, Members: test.members}
+			l.updateHost()
+			if l.Host != test.want {
+				t.Errorf("got %q, want %q", l.Host, test.want)
+			}
+		})
+	}
+}
+
+func handover(from, to string) *ably.Message {
+	js, _ := json.Marshal(client.Handover{Client: to})
+	return &ably.Message{Name: "host", ClientID: from, Data: js}
+}
+
+func TestReplayHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []*ably.Message
+		members []*ably.PresenceMessage
+		want    string
+	}{
+		{
+			"whoever replaced the document",
+			[]*ably.Message{{Name: "new", ClientID: "alice"}},
+			[]*ably.PresenceMessage{member("alice", "host")},
+			"alice",
+		},
+		{
+			"handed over",
+			[]*ably.Message{{Name: "new", ClientID: "alice"}, handover("alice", "bob")},
+			[]*ably.PresenceMessage{member("alice", "member"), member("bob", "host")},
+			"bob",
+		},
+		{
+			"handed over by someone else",
+			[]*ably.Message{{Name: "new", ClientID: "alice"}, handover("bob", "bob")},
+			[]*ably.PresenceMessage{member("alice", "host"), member("bob", "host")},
+			"alice",
+		},
+		{
+			"took over from a host who left",
+			[]*ably.Message{{Name: "new", ClientID: "alice"}, handover("bob", "bob")},
+			[]*ably.PresenceMessage{member("bob", "host")},
+			"bob",
+		},
+		{
+			"took over for someone else",
+			[]*ably.Message{{Name: "new", ClientID: "alice"}, handover("bob", "carol")},
+			[]*ably.PresenceMessage{member("bob", "member"), member("carol", "member")},
+			"alice",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Layout{Members: test.members}
+			for _, msg := range test.history {
+				l.replayHost(msg)
+			}
+			if l.Host != test.want {
+				t.Errorf("got %q, want %q", l.Host, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// A keymap maps keys, or a prefix key and a key like "C-x C-s", to the names
+// of commands.
+type Keymap map[string]string
+
+var keymaps = map[string]Keymap{
+	"nano": {
+		"C-x":     "quit",
+		"C-l":     "log",
+		"C-b":     "blame",
+		"C-a":     "save-as",
+		"C-s":     "save",
+		"C-o":     "open",
+		"C-p":     "command",
+		"C-_":     "goto",
+		"C-w":     "search",
+		"C-u":     "request-save",
+		"C-n":     "new",
+		"C-z":     "restore",
+		"C-f":     "members",
+		"C-e":     "end",
+		"C-k":     "snapshot",
+		"C-r":     "present",
+		"C-space": "mark",
+		"C-g":     "next-member",
+		"C-t":     "history",
+		"C-\\":    "replace-all",
+	},
+	"emacs": {
+		"C-x C-c": "quit",
+		"C-x C-s": "save",
+		"C-x C-w": "save-as",
+		"C-x C-f": "open",
+		"C-x C-l": "log",
+		"C-x C-b": "members",
+		"C-x C-o": "next-member",
+		"C-x C-g": "goto",
+		"C-x C-n": "new",
+		"C-x C-z": "restore",
+		"C-x C-e": "end",
+		"C-x C-k": "snapshot",
+		"C-x C-p": "present",
+		"C-x C-u": "request-save",
+		"C-x C-y": "history",
+		"C-x C-t": "blame",
+		"C-x C-x": "command",
+		"C-x C-r": "replace-all",
+		"C-s":     "search",
+		"C-r":     "search",
+		"C-space": "mark",
+		"C-a":     "beginning-of-line",
+		"C-e":     "end-of-line",
+		"C-f":     "forward-char",
+		"C-b":     "backward-char",
+		"C-n":     "next-line",
+		"C-p":     "previous-line",
+		"C-v":     "page-down",
+	},
+}
+
+// editKeys are the keys the editor handles itself, whatever the keymap, for
+// listing in the help. The terminal doesn't report Ctrl with the arrow, Home
+// and End keys, so the word and document jumps are on Alt (M-) instead.
+var editKeys = []struct {
+	Keys string
+	Help string
+}{
+	{"Home End", "Move to the start or end of the line"},
+	{"PgUp PgDn", "Move up or down a page"},
+	{"M-Left M-b", "Move to the start of the word"},
+	{"M-Right M-f", "Move to the next word"},
+	{"M-Home M-<", "Move to the start of the document"},
+	{"M-End M->", "Move to the end of the document"},
+}
+
+var keyNames = map[string]gocui.Key{
+	"C-space": gocui.KeyCtrlSpace,
+	"C-\\":    gocui.KeyCtrlBackslash,
+	"C-]":     gocui.KeyCtrlRsqBracket,
+	"C-_":     gocui.KeyCtrlUnderscore,
+}
+
+// Keys that are also used for editing and so can't be bound.
+var reservedKeys = map[string]string{
+	"C-h": "backspace",
+	"C-i": "tab",
+	"C-m": "enter",
+	"C-[": "escape",
+}
+
+func init() {
+	for i := 0; i < 26; i++ {
+		name := fmt.Sprintf("C-%c", 'a'+i)
+		if _, ok := reservedKeys[name]; !ok {
+			keyNames[name] = gocui.KeyCtrlA + gocui.Key(i)
+		}
+	}
+	for i := 1; i <= 12; i++ {
+		keyNames[fmt.Sprintf("F%d", i)] = gocui.KeyF1 - gocui.Key(i-1)
+	}
+}
+
+// makeKeymap returns the named profile with overrides applied. Overriding a key
+// with "" unbinds it.
+func makeKeymap(profile string, overrides map[string]string) (Keymap, error) {
+	if profile == "" {
+		profile = "nano"
+	}
+	base, ok := keymaps[profile]
+	if !ok {
+		return nil, errors.New("unknown keymap " + profile)
+	}
+	keymap := make(Keymap)
+	for key, command := range base {
+		keymap[key] = command
+	}
+	for key, command := range overrides {
+		if command == "" {
+			delete(keymap, key)
+			continue
+		}
+		keymap[key] = command
+	}
+
+	for seq, command := range keymap {
+		keys := strings.Fields(seq)
+		if len(keys) == 0 || len(keys) > 2 {
+			return nil, errors.New("bad key " + seq)
+		}
+		for _, key := range keys {
+			if what, ok := reservedKeys[key]; ok {
+				return nil, errors.New(key + " is " + what + " and can't be bound")
+			}
+			if _, ok := keyNames[key]; !ok {
+				return nil, errors.New("unknown key " + key)
+			}
+		}
+		if findCommand(command) == nil {
+			return nil, errors.New("unknown command " + command + " for " + seq)
+		}
+	}
+	return keymap, nil
+}
+
+// isPrefix reports whether any binding starts with key followed by another.
+func (k Keymap) isPrefix(key string) bool {
+	for seq := range k {
+		if strings.HasPrefix(seq, key+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// keysFor returns the key sequence bound to a command, the shortest first.
+func (k Keymap) keysFor(command string) string {
+	var found []string
+	for seq, name := range k {
+		if name == command {
+			found = append(found, seq)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return len(found[i]) < len(found[j]) || len(found[i]) == len(found[j]) && found[i] < found[j]
+	})
+	if len(found) == 0 {
+		return ""
+	}
+	return found[0]
+}
+
+// The commands shown in the keys bar, in order, and what they are called there.
+// Only the first few fit, so the command line comes early as every other
+// command can be found from it.
+var keyHelp = []struct{ Command, Label string }{
+	{"quit", "Exit"},
+	{"save", "Save"},
+	{"save-as", "Save As"},
+	{"command", "Cmds"},
+	{"search", "Find"},
+	{"new", "New"},
+	{"end", "End"},
+}
+
+// hint returns how to run a command to do what, for adding to a message.
+func (l *Layout) hint(command, what string) string {
+	if keys := l.Keymap.keysFor(command); keys != "" {
+		return keys + " to " + what
+	}
+	return "the " + command + " command to " + what
+}
+
+// help returns the text of the keys bar, as much as fits in width.
+func (k Keymap) help(width int) string {
+	var help []string
+	length := 0
+	for _, h := range keyHelp {
+		seq := k.keysFor(h.Command)
+		if seq == "" {
+			continue
+		}
+		item := seq + " " + h.Label
+		if length+len(item) > width {
+			break
+		}
+		help = append(help, item)
+		length += len(item) + 2
+	}
+	return strings.Join(help, "  ")
+}
+
+// bindKeys binds every key in the keymap. Each key has one handler that works
+// out which command to run from it and any prefix key pressed before it,
+// passing it on to the view's editor if it isn't bound to anything.
+func (l *Layout) bindKeys(gui *gocui.Gui) error {
+	bound := make(map[string]bool)
+	for seq := range l.Keymap {
+		for _, key := range strings.Fields(seq) {
+			if bound[key] {
+				continue
+			}
+			bound[key] = true
+			err := gui.SetKeybinding("", keyNames[key], gocui.ModNone, l.keyHandler(key))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// closer returns what closes the view a command opens, for when its keys are
+// pressed again in that view.
+func (l *Layout) closer(command string) func(*gocui.Gui, *gocui.View) error {
+	switch command {
+	case "members":
+		return l.closePicker
+	case "history":
+		return l.closeHistory
+	}
+	return nil
+}
+
+func (l *Layout) keyHandler(key string) func(*gocui.Gui, *gocui.View) error {
+	return func(gui *gocui.Gui, v *gocui.View) error {
+		seq := key
+		if l.Prefix != "" {
+			seq = l.Prefix + " " + key
+			l.Prefix = ""
+		}
+
+		name, ok := l.Keymap[seq]
+		if !ok && seq == key && l.Keymap.isPrefix(key) {
+			l.Prefix = key
+			l.Editor.Nodify(key + "-")
+			return nil
+		}
+		if close := l.closer(name); ok && close != nil && v != nil && v.Name() == name {
+			return close(gui, v)
+		}
+		command := findCommand(name)
+		if !ok || command == nil || !command.Global && (l.Player != nil || v == nil || v.Name() != "editor") {
+			if v != nil && v.Editable && v.Editor != nil {
+				v.Editor.Edit(v, keyNames[key], 0, gocui.ModNone)
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(command.Args, "<") {
+			return l.openCommand(command.Name+" ")(gui, v)
+		}
+		err := command.Run(l, nil)
+		if err != nil && err != gocui.ErrQuit {
+			l.Editor.Nodify(err.Error())
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jroimartin/gocui"
+)
+
+func TestMakeKeymap(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   string
+		overrides map[string]string
+		key       string
+		want      string
+		err       bool
+	}{
+		{"nano by default", "", nil, "C-s", "save", false},
+		{"emacs", "emacs", nil, "C-x C-s", "save", false},
+		{"unknown profile", "vi", nil, "", "", true},
+		{"override", "", map[string]string{"C-s": "search"}, "C-s", "search", false},
+		{"new binding", "", map[string]string{"F5": "save"}, "F5", "save", false},
+		{"new sequence", "", map[string]string{"C-y C-s": "save"}, "C-y C-s", "save", false},
+		{"unbind", "", map[string]string{"C-s": ""}, "C-s", "", false},
+		{"unbind what isn't bound", "", map[string]string{"F5": ""}, "F5", "", false},
+		{"reserved key", "", map[string]string{"C-h": "save"}, "", "", true},
+		{"reserved key after a prefix", "", map[string]string{"C-y C-m": "save"}, "", "", true},
+		{"unknown key", "", map[string]string{"Super-s": "save"}, "", "", true},
+		{"unknown command", "", map[string]string{"C-s": "explode"}, "", "", true},
+		{"too many keys", "", map[string]string{"C-y C-y C-s": "save"}, "", "", true},
+		{"no keys", "", map[string]string{" ": "save"}, "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keymap, err := makeKeymap(test.profile, test.overrides)
+			if test.err {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keymap[test.key]; got != test.want {
+				t.Errorf("got %q bound to %s, want %q", got, test.key, test.want)
+			}
+		})
+	}
+}
+
+func TestMakeKeymapCopies(t *testing.T) {
+	_, err := makeKeymap("nano", map[string]string{"C-s": "search", "C-o": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keymaps["nano"]["C-s"] != "save" || keymaps["nano"]["C-o"] != "open" {
+		t.Error("overrides changed the profile")
+	}
+}
+
+func TestIsPrefix(t *testing.T) {
+	emacs, _ := makeKeymap("emacs", nil)
+	nano, _ := makeKeymap("nano", map[string]string{"C-y C-s": "save"})
+	tests := []struct {
+		name   string
+		keymap Keymap
+		key    string
+		want   bool
+	}{
+		{"prefix", emacs, "C-x", true},
+		{"bound alone", emacs, "C-s", false},
+		{"unbound", emacs, "C-y", false},
+		{"not a prefix", nano, "C-x", false},
+		{"prefix and more", nano, "C-y", true},
+		{"part of a key", nano, "C-", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.keymap.isPrefix(test.key); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// press runs the handlers for keys in turn, as gocui would with v current.
+func press(t *testing.T, l *Layout, gui *gocui.Gui, v *gocui.View, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		err := l.keyHandler(key)(gui, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKeyHandlerPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		keys    []string
+		log     bool
+		prefix  string
+	}{
+		{"single key", "nano", []string{"C-l"}, true, ""},
+		{"prefix", "emacs", []string{"C-x"}, false, "C-x"},
+		{"prefix and key", "emacs", []string{"C-x", "C-l"}, true, ""},
+		{"unbound after the prefix", "emacs", []string{"C-x", "C-a"}, false, ""},
+		{"key without the prefix", "emacs", []string{"C-l"}, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := testLayout("host")
+			l.Keymap, _ = makeKeymap(test.profile, nil)
+			press(t, l, l.Editor.Gui, nil, test.keys...)
+			if l.Log != test.log {
+				t.Errorf("got log shown %v, want %v", l.Log, test.log)
+			}
+			if l.Prefix != test.prefix {
+				t.Errorf("got prefix %q, want %q", l.Prefix, test.prefix)
+			}
+		})
+	}
+}
+
+func TestKeyHandlerClosesViews(t *testing.T) {
+	tests := []struct {
+		profile string
+		keys    []string
+	}{
+		{"nano", []string{"C-f"}},
+		{"emacs", []string{"C-x", "C-b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.profile, func(t *testing.T) {
+			l := testLayout("host")
+			l.Keymap, _ = makeKeymap(test.profile, nil)
+			gui := newTestGui(80, 24)
+			members, _ := gui.SetView("members", 60, 0, 79, 20)
+			l.Picking = true
+			press(t, l, gui, members, test.keys...)
+			if l.Picking {
+				t.Error("the members view is still open")
+			}
+		})
+	}
+
+	l := testLayout("host")
+	l.Keymap, _ = makeKeymap("nano", map[string]string{"C-t": "", "F2": "history"})
+	gui := newTestGui(80, 24)
+	history, _ := gui.SetView("history", 0, 0, 40, 20)
+	l.History = &HistoryView{}
+	press(t, l, gui, history, "C-t")
+	if l.History == nil {
+		t.Error("an unbound key closed the history")
+	}
+	press(t, l, gui, history, "F2")
+	if l.History != nil {
+		t.Error("the history key didn't close the history")
+	}
+}
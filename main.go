@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -11,10 +10,16 @@ import (
 	"os/user"
 	"time"
 
+	"github.com/ably-labs/sync-edit/client"
 	"github.com/ably/ably-go/ably"
 	"github.com/jroimartin/gocui"
 )
 
+const clientVersion = "sync-edit/0.1"
+
+// How long to wait for changes to be sent when quitting.
+const shutdownTimeout = 5 * time.Second
+
 type State struct {
 	FileName string
 	LastSend time.Time
@@ -56,19 +61,28 @@ func run() error {
 		return nil
 	}
 
+	if args.Replay {
+		return replay(&args)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
 	realtime, err = newRealtime()
 	if err != nil {
 		return err
 	}
 	defer realtime.Close()
 
-	if args.Join || args.Cat {
+	if args.Join || args.Cat || args.Blame || args.Export {
 		code = args.Arg
 	} else {
 		code = makeTag()
 	}
 
-	channel := realtime.Channels.Get("sync-edit:" + code)
+	channel := realtime.Channels.Get(client.ChannelName(code))
 	err = channel.Attach(ctx)
 	if err != nil {
 		return err
@@ -79,15 +93,30 @@ func run() error {
 		return err
 	}
 
-	if (args.Join || args.Cat) && len(presense) == 0 {
+	if (args.Join || args.Cat || args.Blame || args.Export) && len(presense) == 0 {
 		return errors.New(fmt.Sprintf("session '%s' does not exist", code))
-	} else if !(args.Join || args.Cat) && len(presense) != 0 {
+	} else if !(args.Join || args.Cat || args.Blame || args.Export) && len(presense) != 0 {
 		return errors.New(fmt.Sprintf("session '%s' already exists", code))
 	}
 
-	layout := &Layout{Code: code, Cursors: make(map[string]Cursor, 0), Id: realtime.Auth.ClientID()}
+	layout := &Layout{Code: code, Cursors: make(map[string]client.Cursor, 0), Id: realtime.Auth.ClientID(), Members: presense, Config: config}
+	if !args.Join {
+		layout.Host = layout.Id
+	}
+	layout.Keymap, _ = makeKeymap(config.Keymap, config.Keys)
+
+	if args.Record != "" {
+		layout.Recorder, err = NewRecorder(args.Record)
+		if err != nil {
+			return err
+		}
+		defer layout.Recorder.Close()
+		for _, member := range presense {
+			layout.Recorder.Presence(member)
+		}
+	}
 
-	if !(args.Join || args.Cat) && args.Arg != "" {
+	if !(args.Join || args.Cat || args.Blame || args.Export) && args.Arg != "" {
 		file, err = os.ReadFile(args.Arg)
 		if err != nil {
 			return err
@@ -96,7 +125,15 @@ func run() error {
 	}
 
 	if args.Cat {
-		return cat(ctx, channel)
+		return cat(ctx, channel, &args)
+	}
+
+	if args.Blame {
+		return blame(ctx, channel)
+	}
+
+	if args.Export {
+		return export(ctx, channel, &args)
 	}
 
 	gui, err = initGui()
@@ -106,23 +143,44 @@ func run() error {
 
 	gui.SetManager(layout)
 	layout.Layout(gui)
-	edit, err = MakeEditor(ctx, file, !args.Join, channel, gui, layout)
-	if err != nil {
-		return err
-	}
-	layout.Editor = edit
 
+	// Subscribe before the editor replays the history, so that a recording
+	// misses nothing. The updates only run once the main loop starts.
 	_, err = channel.Presence.SubscribeAll(ctx, func(msg *ably.PresenceMessage) {
+		if layout.Recorder != nil {
+			layout.Recorder.Presence(msg)
+		}
 		presense, err := channel.Presence.Get(ctx)
 		if err == nil {
-			layout.Members = presense
-			gui.Update(func(gui *gocui.Gui) error { return nil })
+			gui.Update(func(gui *gocui.Gui) error {
+				layout.Members = presense
+				layout.handlePresence(msg)
+				layout.updateHost()
+				layout.tallyResets()
+				return nil
+			})
 		}
 	})
 	if err != nil {
 		return err
 	}
 
+	edit, err = MakeEditor(ctx, file, !args.Join, channel, gui, layout)
+	if err != nil {
+		return err
+	}
+	layout.Editor = edit
+	if config.Vim {
+		layout.Vim = &Vim{Editor: edit}
+	}
+	if args.Join {
+		// The history said who the host was, but they may have left since.
+		gui.Update(func(gui *gocui.Gui) error {
+			layout.updateHost()
+			return nil
+		})
+	}
+
 	channel.SubscribeAll(ctx, func(msg *ably.Message) {
 		gui.Update(func(gui *gocui.Gui) error {
 			log, _ := gui.View("log")
@@ -130,14 +188,40 @@ func run() error {
 			return nil
 		})
 	})
-	_, err = channel.Subscribe(ctx, "cursor", func(msg *ably.Message) {
-		var cursor Cursor
-		err := json.Unmarshal([]byte(msg.Data.(string)), &cursor)
+	_, err = channel.Subscribe(ctx, "host", func(msg *ably.Message) {
+		gui.Update(func(gui *gocui.Gui) error {
+			layout.handleHandover(msg)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	handlers := map[string]func(*ably.Message){
+		"saved":         layout.handleSaved,
+		"save-request":  layout.handleSaveRequest,
+		"save-result":   layout.handleSaveResult,
+		"reset-request": layout.handleResetRequest,
+		"reset-vote":    layout.handleResetVote,
+		"reset-result":  layout.handleResetResult,
+	}
+	for name, handle := range handlers {
+		handle := handle
+		_, err = channel.Subscribe(ctx, name, func(msg *ably.Message) {
+			gui.Update(func(gui *gocui.Gui) error {
+				handle(msg)
+				return nil
+			})
+		})
 		if err != nil {
-			return
+			return err
 		}
-		layout.Cursors[msg.ClientID] = cursor
-		gui.Update(func(gui *gocui.Gui) error { return nil })
+	}
+	_, err = channel.Subscribe(ctx, "cursor", func(msg *ably.Message) {
+		gui.Update(func(gui *gocui.Gui) error {
+			layout.handleCursor(msg)
+			return nil
+		})
 	})
 	if err != nil {
 		return err
@@ -152,7 +236,12 @@ func run() error {
 	if name == "" {
 		name = user.Username
 	}
-	err = channel.Presence.Enter(ctx, name)
+	role := "member"
+	if !args.Join {
+		role = "host"
+	}
+	edit.Presence = client.Presence{Name: name, Role: role, Client: clientVersion, File: layout.FileName}
+	err = channel.Presence.Enter(ctx, edit.Presence.Data())
 	if err != nil {
 		return err
 	}
@@ -162,18 +251,36 @@ func run() error {
 	}
 
 	err = gui.MainLoop()
+	gui.Close()
 	if err != nil && err != gocui.ErrQuit {
 		return err
 	}
-	defer gui.Close()
 
-	return nil
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	return edit.Close(ctx)
 }
 
-func newRealtime() (*ably.Realtime, error) {
+func ablyKey() (string, error) {
 	key, ok := os.LookupEnv("ABLY_KEY")
 	if !ok {
-		return nil, errors.New("ABLY_KEY not set")
+		return "", errors.New("ABLY_KEY not set")
+	}
+	return key, nil
+}
+
+func newREST() (*ably.REST, error) {
+	key, err := ablyKey()
+	if err != nil {
+		return nil, err
+	}
+	return ably.NewREST(ably.WithKey(key), ably.WithLogHandler(&Logger{}))
+}
+
+func newRealtime() (*ably.Realtime, error) {
+	key, err := ablyKey()
+	if err != nil {
+		return nil, err
 	}
 	return ably.NewRealtime(
 		ably.WithKey(key),
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+)
+
+func (e *Editor) handleMeta(msg *ably.Message) {
+	meta := client.MetaOf(msg)
+	if meta == nil {
+		return
+	}
+	e.Meta = *meta
+}
+
+// renamed tells everyone when the host saves the document under a new name.
+func (e *Editor) renamed(file string) {
+	name := filepath.Base(file)
+	if name == e.Meta.File {
+		return
+	}
+	e.Meta.File = name
+	if language := client.Language(name); language != "" {
+		e.Meta.Language = language
+	}
+	meta := e.Meta
+	e.Queue <- &meta
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/jroimartin/gocui"
+)
+
+// openFile shows the file dialog for loading a local file into the session.
+func (l *Layout) openFile(gui *gocui.Gui, v *gocui.View) error {
+	if !l.isHost() {
+		l.Editor.Nodify("Only the host can open files")
+		return nil
+	}
+	if l.Ended {
+		return nil
+	}
+	l.Save = &Save{Editor: l.Editor, Force: true, Open: true}
+	return nil
+}
+
+// open reads name and, once confirmed, asks everyone whether to replace the
+// document with it.
+func (s *Save) open(name string) {
+	if name == "" {
+		s.Message = "Enter a file name"
+		return
+	}
+	name = expandHome(name)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		s.Message = err.Error()
+		return
+	}
+	s.Close()
+
+	l := s.Editor.Layout
+	label := "Ask everyone to replace the document with " + filepath.Base(name) + "? (y/n)"
+	if s.Editor.dirty() {
+		label = "Discard unsaved changes and ask everyone to replace the document with " + filepath.Base(name) + "? (y/n)"
+	}
+	l.ask(&Prompt{
+		Editor: s.Editor,
+		Label:  label,
+		OnEnter: func(value string) error {
+			switch value {
+			case "y", "yes":
+				l.requestOpen(name, data)
+			case "n", "no", "":
+			default:
+				return errors.New("Type y to open the file or n to cancel")
+			}
+			return nil
+		},
+	})
+}
+
+// opening is a file the host has asked to open, kept until the request is
+// decided.
+type opening struct {
+	Name string
+	Data []byte
+}
+
+// requestOpen asks everyone whether to replace the document with a file, the
+// same way as a reset.
+func (l *Layout) requestOpen(name string, data []byte) {
+	request := &client.ResetRequest{Id: makeTag(), Open: filepath.Base(name)}
+	if l.Opens == nil {
+		l.Opens = make(map[string]opening)
+	}
+	l.Opens[request.Id] = opening{Name: name, Data: data}
+	l.Editor.Queue <- request
+	l.Editor.Nodify("Asked everyone to " + resetAction(*request))
+}
+
+// load publishes data as a "new" document along with its metadata, approving
+// the request with the given ID. Like C-n, the editor can't be typed in until
+// the "new" comes back.
+func (e *Editor) load(id string, name string, data []byte) {
+	e.EditMux.Lock()
+	e.flushChanges(true)
+	e.EditMux.Unlock()
+
+	e.Layout.FileName = name
+	e.Layout.Editable = false
+	hash := textHash(client.ApplyNew(string(data), nil))
+	e.Saved = &hash
+	e.onDisk(name, data)
+	e.Meta = client.DetectMeta(name, data)
+	meta := e.Meta
+
+	e.Queue <- replace(id, string(data))
+	e.Queue <- &meta
+	e.UpdatePresence(func(p *client.Presence) { p.File = name })
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// layoutEdges shows an arrow on the top or bottom edge of the editor, in the
+// member's colour, for every cursor that is above or below the view.
+func (l *Layout) layoutEdges(gui *gocui.Gui, editor *gocui.View) error {
+	left, top, _, bottom, err := gui.ViewPosition("editor")
+	if err != nil {
+		return err
+	}
+	xo, yo := editor.Origin()
+	xs, ys := editor.Size()
+
+	wanted := make(map[string]bool)
+	for _, member := range l.Members {
+		pos, ok := l.Cursors[member.ClientID]
+		if member.ClientID == l.Id || !ok {
+			continue
+		}
+
+		var y int
+		var arrow string
+		if pos.Y < yo {
+			y, arrow = top, "▲"
+		} else if pos.Y >= yo+ys {
+			y, arrow = bottom, "▼"
+		} else {
+			continue
+		}
+
+		x := pos.X - xo
+		if x < 0 {
+			x = 0
+		} else if x >= xs {
+			x = xs - 1
+		}
+		x += left + 1
+
+		name := "edge-" + member.ClientID
+		wanted[name] = true
+		view, err := gui.SetView(name, x-1, y-1, x+1, y+1)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			view.Frame = false
+		}
+		view.FgColor = l.memberColour(member.ClientID)
+		view.Clear()
+		fmt.Fprint(view, arrow)
+	}
+
+	var stale []string
+	for _, view := range gui.Views() {
+		name := view.Name()
+		if strings.HasPrefix(name, "edge-") && !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+	for _, name := range stale {
+		gui.DeleteView(name)
+	}
+	return nil
+}
+
+// layoutMinimap draws a scroll bar over the right edge of the editor, showing
+// which part of the document is in view and the line every member is on.
+func (l *Layout) layoutMinimap(gui *gocui.Gui, editor *gocui.View) error {
+	_, top, right, bottom, err := gui.ViewPosition("editor")
+	if err != nil {
+		return err
+	}
+
+	minimap, err := gui.SetView("minimap", right-1, top, right+1, bottom)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		minimap.Frame = false
+	}
+	minimap.Clear()
+
+	_, yo := editor.Origin()
+	_, ys := editor.Size()
+	rows := bottom - top - 1
+	lines := len(l.Editor.Text)
+	if lines < ys {
+		lines = ys
+	}
+
+	// row returns the row of the minimap that shows the given line
+	row := func(line int) int {
+		return line * rows / lines
+	}
+
+	marks := make([]string, rows)
+	for r := range marks {
+		marks[r] = "│"
+	}
+	for r := row(yo); r <= row(yo+ys-1) && r < rows; r++ {
+		marks[r] = "┃"
+	}
+	for _, member := range l.Members {
+		pos, ok := l.Cursors[member.ClientID]
+		if member.ClientID == l.Id || !ok {
+			continue
+		}
+		r := row(pos.Y)
+		if r >= 0 && r < rows {
+			marks[r] = fmt.Sprintf("\x1b[0;%dm●\x1b[0m", l.memberColour(member.ClientID)+29)
+		}
+	}
+	fmt.Fprint(minimap, strings.Join(marks, "\n"))
+	return nil
+}
+
+// nextMember moves the cursor to the next member's cursor, in the order they
+// are shown in the members pane.
+func (l *Layout) nextMember(gui *gocui.Gui, v *gocui.View) error {
+	var ids []string
+	for _, member := range l.Members {
+		if _, ok := l.Cursors[member.ClientID]; ok && member.ClientID != l.Id {
+			ids = append(ids, member.ClientID)
+		}
+	}
+	if len(ids) == 0 {
+		l.Editor.Nodify("Nobody else has a cursor")
+		return nil
+	}
+
+	next := ids[0]
+	for i, id := range ids {
+		if id == l.Jumped && i+1 < len(ids) {
+			next = ids[i+1]
+		}
+	}
+	l.Jumped = next
+
+	e := l.Editor
+	e.EditMux.Lock()
+	defer e.EditMux.Unlock()
+	e.flushChanges(false)
+
+	pos := l.Cursors[next]
+	if pos.Y >= len(e.Text) {
+		pos.Y = len(e.Text) - 1
+	}
+	if pos.X > len(e.Text[pos.Y]) {
+		pos.X = len(e.Text[pos.Y])
+	}
+	xo, yo := v.Origin()
+	e.moveTo(pos.X, pos.Y, xo, yo)
+	e.flushChanges(true)
+	e.Nodify("At " + l.authorName(next) + "'s cursor")
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// locked reports whether someone else is presenting and the editor is
+// following them.
+func (l *Layout) locked() bool {
+	return l.Presenter != "" && l.Presenter != l.Id && !l.Detached
+}
+
+// present reports whether the member with the given ClientID is present.
+func (l *Layout) present(id string) bool {
+	for _, member := range l.Members {
+		if member.ClientID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// togglePresenting starts or stops a presentation for the host, and detaches from or
+// re-syncs with the presenter for everyone else.
+func (l *Layout) togglePresenting(gui *gocui.Gui, v *gocui.View) error {
+	switch {
+	case l.Presenter == l.Id:
+		l.publishPresentation(false)
+	case l.Presenter != "":
+		l.Detached = !l.Detached
+		if l.Detached {
+			l.Editor.Nodify("Detached from the presentation, " + l.hint("present", "re-sync"))
+		} else {
+			l.Editor.Nodify("Following the presentation, " + l.hint("present", "detach"))
+			l.followCursor(l.Presenter)
+		}
+	case !l.isHost():
+		l.Editor.Nodify("Only the host can present")
+	default:
+		l.publishPresentation(true)
+	}
+	return nil
+}
+
+func (l *Layout) publishPresentation(active bool) {
+	l.Editor.Queue <- &client.Presentation{Active: active}
+}
+
+// handlePresent starts or stops following a presentation. Only the host can
+// start presenting, and only the presenter can stop.
+func (l *Layout) handlePresent(msg *ably.Message) {
+	var p client.Presentation
+	err := client.Decode(msg, &p)
+	if err != nil {
+		return
+	}
+
+	if p.Active {
+		if msg.ClientID != l.Host || !l.present(msg.ClientID) {
+			return
+		}
+		l.Presenter = msg.ClientID
+		l.Detached = false
+		l.Following = ""
+		if msg.ClientID == l.Id {
+			l.Editor.Nodify("Presenting, " + l.hint("mark", "select") + ", " + l.hint("present", "stop"))
+		} else {
+			l.Editor.Nodify(l.authorName(msg.ClientID) + " is presenting, " + l.hint("present", "detach"))
+		}
+		l.followCursor(l.leader())
+	} else if msg.ClientID == l.Presenter {
+		l.stopPresenting()
+	}
+}
+
+func (l *Layout) stopPresenting() {
+	l.Presenter = ""
+	l.Detached = false
+	l.Mark = nil
+	l.Editor.Nodify("The presentation has finished")
+}
+
+func (l *Layout) toggleMark(gui *gocui.Gui, v *gocui.View) error {
+	if l.Mark != nil {
+		l.Mark = nil
+		return nil
+	}
+	x, y := l.Editor.cursorPos()
+	l.Mark = &client.Cursor{X: x, Y: y}
+	return nil
+}
+
+// selection returns the start and end of the selection to highlight, either
+// the presenter's or the local one while presenting.
+func (l *Layout) selection() (client.Cursor, client.Cursor, bool) {
+	var start, end client.Cursor
+	if l.Vim != nil && (l.Vim.Mode == vimVisual || l.Vim.Mode == vimVisualLine) {
+		start = client.Cursor{X: l.Vim.Anchor.X, Y: l.Vim.Anchor.Y}
+		end.X, end.Y = l.Editor.cursorPos()
+		if end.Y < start.Y || end.Y == start.Y && end.X < start.X {
+			start, end = end, start
+		}
+		end.X++
+		if l.Vim.Mode == vimVisualLine {
+			start.X, end.X = 0, 1<<30
+		}
+		return start, end, true
+	}
+	switch {
+	case l.Presenter == "":
+		return start, end, false
+	case l.Presenter == l.Id:
+		if l.Mark == nil {
+			return start, end, false
+		}
+		start = *l.Mark
+		end.X, end.Y = l.Editor.cursorPos()
+	default:
+		pos, ok := l.Cursors[l.Presenter]
+		if !ok || !pos.Mark {
+			return start, end, false
+		}
+		start = client.Cursor{X: pos.MX, Y: pos.MY}
+		end = client.Cursor{X: pos.X, Y: pos.Y}
+	}
+
+	if end.Y < start.Y || end.Y == start.Y && end.X < start.X {
+		start, end = end, start
+	}
+	return start, end, true
+}
+
+// layoutSelection highlights the selection with a view over each selected
+// line, the same way remote cursors are drawn.
+func (l *Layout) layoutSelection(gui *gocui.Gui, editor *gocui.View) error {
+	left, top, _, _, err := gui.ViewPosition("editor")
+	if err != nil {
+		return err
+	}
+	xo, yo := editor.Origin()
+	xs, ys := editor.Size()
+	lines := editor.BufferLines()
+	start, end, ok := l.selection()
+
+	wanted := make(map[string]bool)
+	for y := start.Y; ok && y <= end.Y; y++ {
+		if y < yo || y >= yo+ys || y >= len(lines) {
+			continue
+		}
+		line := lines[y]
+		sx, ex := 0, len(line)
+		if y == start.Y {
+			sx = start.X
+		}
+		if y == end.Y {
+			ex = end.X
+		}
+		if sx < xo {
+			sx = xo
+		}
+		if ex > xo+xs {
+			ex = xo + xs
+		}
+		if ex > len(line) {
+			ex = len(line)
+		}
+		if ex <= sx {
+			continue
+		}
+
+		name := fmt.Sprintf("select-%d", y)
+		wanted[name] = true
+		view, err := gui.SetView(name, left+sx-xo, top+y-yo, left+ex-xo+1, top+y-yo+2)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			view.Frame = false
+		}
+		owner := l.Presenter
+		if owner == "" {
+			owner = l.Id
+		}
+		view.BgColor = l.memberColour(owner)
+		view.FgColor = gocui.ColorBlack
+		view.Clear()
+		fmt.Fprint(view, line[sx:ex])
+	}
+
+	var stale []string
+	for _, view := range gui.Views() {
+		name := view.Name()
+		if strings.HasPrefix(name, "select-") && !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+	for _, name := range stale {
+		gui.DeleteView(name)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Prompt asks the user for a single line of text. Enter calls OnEnter with the
+// text and closes the prompt unless it returns an error, Esc cancels it.
+type Prompt struct {
+	Editor  *Editor
+	Label   string
+	Value   string
+	OnEnter func(value string) error
+}
+
+func (p *Prompt) Layout(gui *gocui.Gui) error {
+	maxX, maxY := gui.Size()
+	var input, label *gocui.View
+
+	_, err := gui.SetView("prompt-box", maxX/2-33, maxY/2-3, maxX/2+33, maxY/2+3)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+	}
+
+	label, err = gui.SetView("prompt-label", maxX/2-30, maxY/2-2, maxX/2+30, maxY/2)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		fmt.Fprint(label, p.Label)
+		label.Frame = false
+	}
+
+	input, err = gui.SetView("prompt-input", maxX/2-30, maxY/2, maxX/2+30, maxY/2+2)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		fmt.Fprint(input, p.Value)
+		input.SetCursor(len(p.Value), 0)
+	}
+	input.Editor = p
+	input.Editable = true
+
+	gui.SetCurrentView("prompt-input")
+	return nil
+}
+
+func (p *Prompt) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	switch {
+	case key == gocui.KeyEnter:
+		err := p.OnEnter(strings.TrimSpace(v.Buffer()))
+		if err != nil {
+			label, _ := p.Editor.Gui.View("prompt-label")
+			label.Clear()
+			fmt.Fprint(label, err)
+			return
+		}
+		p.Close()
+	case key == gocui.KeyEsc:
+		p.Close()
+	default:
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+	}
+}
+
+// ask shows p, or if another prompt is open, queues it to be shown once the
+// ones before it are closed, so that an answer always goes to the question on
+// screen.
+func (l *Layout) ask(p *Prompt) {
+	if l.Prompt == nil {
+		l.Prompt = p
+		return
+	}
+	l.Prompts = append(l.Prompts, p)
+}
+
+// Close closes p if it is open, showing the next queued prompt, or takes it
+// out of the queue if it hasn't been shown yet.
+func (p *Prompt) Close() {
+	l := p.Editor.Layout
+	if l.Prompt != p {
+		for i, queued := range l.Prompts {
+			if queued == p {
+				l.Prompts = append(l.Prompts[:i], l.Prompts[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	p.Editor.Gui.DeleteView("prompt-box")
+	p.Editor.Gui.DeleteView("prompt-input")
+	p.Editor.Gui.DeleteView("prompt-label")
+	l.Prompt = nil
+	if len(l.Prompts) > 0 {
+		l.Prompt = l.Prompts[0]
+		l.Prompts = l.Prompts[1:]
+	}
+}
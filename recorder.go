@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/ably/ably-go/ably"
+)
+
+// Record is one line of a recording file, either a channel message or a
+// presence change.
+type Record struct {
+	Kind      string      `json:"kind"`
+	Timestamp int64       `json:"timestamp"`
+	Id        string      `json:"id,omitempty"`
+	ClientId  string      `json:"clientId,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Action    string      `json:"action,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+func (r *Record) Message() *ably.Message {
+	return &ably.Message{ID: r.Id, ClientID: r.ClientId, Name: r.Name, Data: r.Data, Timestamp: r.Timestamp}
+}
+
+type Recorder struct {
+	file *os.File
+	enc  *json.Encoder
+	mux  sync.Mutex
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (r *Recorder) write(record *Record) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.enc.Encode(record)
+}
+
+func (r *Recorder) Message(msg *ably.Message) {
+	data := msg.Data
+	if b, ok := data.([]byte); ok {
+		data = string(b)
+	}
+	r.write(&Record{Kind: "message", Timestamp: msg.Timestamp, Id: msg.ID, ClientId: msg.ClientID, Name: msg.Name, Data: data})
+}
+
+func (r *Recorder) Presence(msg *ably.PresenceMessage) {
+	r.write(&Record{Kind: "presence", Timestamp: msg.Timestamp, Id: msg.ID, ClientId: msg.ClientID, Action: msg.Action.String(), Data: msg.Data})
+}
+
+func (r *Recorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.file.Close()
+}
+
+func readRecording(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// Gaps longer than this between recorded messages are shortened when playing
+// them back, so idle time in a session doesn't have to be sat through.
+const maxReplayGap = 5 * time.Second
+
+// Player plays a recording back through the editor, as if the messages were
+// arriving from ably.
+type Player struct {
+	Layout  *Layout
+	Records []*Record
+	Pos     int
+	Speed   float64
+	Paused  bool
+	members []*ably.PresenceMessage
+	mux     sync.Mutex
+	wake    chan struct{}
+}
+
+func replay(args *Arguments) error {
+	records, err := readRecording(args.Arg)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New("recording is empty")
+	}
+
+	gui, err := initGui()
+	if err != nil {
+		return err
+	}
+	defer gui.Close()
+
+	layout := &Layout{Code: args.Arg, Cursors: make(map[string]client.Cursor, 0)}
+	layout.Editor = &Editor{Gui: gui, Layout: layout, Text: [][]byte{{}}}
+	player := &Player{Layout: layout, Records: records, Speed: args.Speed, wake: make(chan struct{}, 1)}
+	if player.Speed <= 0 {
+		player.Speed = 1
+	}
+	layout.Player = player
+
+	gui.SetManager(layout)
+	go player.run()
+
+	err = gui.MainLoop()
+	if err != nil && err != gocui.ErrQuit {
+		return err
+	}
+	return nil
+}
+
+func (p *Player) run() {
+	for {
+		p.mux.Lock()
+		var timer <-chan time.Time
+		if !p.Paused && p.Pos < len(p.Records) {
+			var gap time.Duration
+			if p.Pos > 0 {
+				gap = time.Duration(p.Records[p.Pos].Timestamp-p.Records[p.Pos-1].Timestamp) * time.Millisecond
+			}
+			if gap < 0 {
+				gap = 0
+			} else if gap > maxReplayGap {
+				gap = maxReplayGap
+			}
+			timer = time.After(time.Duration(float64(gap) / p.Speed))
+		}
+		p.mux.Unlock()
+
+		select {
+		case <-p.wake:
+		case <-timer:
+			// Step on the gui's goroutine, like messages from ably, and wait
+			// for it so the next gap is worked out from the new position.
+			done := make(chan struct{})
+			p.Layout.Editor.Gui.Update(func(gui *gocui.Gui) error {
+				p.mux.Lock()
+				if !p.Paused && p.Pos < len(p.Records) {
+					p.step()
+				}
+				p.mux.Unlock()
+				close(done)
+				return nil
+			})
+			<-done
+		}
+	}
+}
+
+func (p *Player) redraw() {
+	p.Layout.Editor.Gui.Update(func(gui *gocui.Gui) error { return nil })
+}
+
+// poke wakes the playback loop up after the position, speed or pause state
+// has changed.
+func (p *Player) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	p.redraw()
+}
+
+func (p *Player) step() {
+	record := p.Records[p.Pos]
+	p.Pos++
+
+	switch record.Kind {
+	case "message":
+		msg := record.Message()
+		p.Layout.Editor.handleMessage(msg)
+		if msg.Name == "cursor" {
+			p.Layout.handleCursor(msg)
+		}
+	case "presence":
+		msg := &ably.PresenceMessage{Message: *record.Message()}
+		for i, member := range p.members {
+			if member.ClientID == msg.ClientID {
+				p.members = append(p.members[:i], p.members[i+1:]...)
+				break
+			}
+		}
+		if record.Action != "LEAVE" && record.Action != "ABSENT" {
+			p.members = append(p.members, msg)
+		} else {
+			msg.Action = ably.PresenceActionLeave
+			p.Layout.handlePresence(msg)
+		}
+		p.Layout.Members = append([]*ably.PresenceMessage(nil), p.members...)
+	}
+}
+
+// seek replays the recording from the start up to, but not including, the
+// record at pos.
+func (p *Player) seek(pos int) {
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(p.Records) {
+		pos = len(p.Records)
+	}
+
+	e := p.Layout.Editor
+	e.EditMux.Lock()
+	e.Text = [][]byte{{}}
+	e.Authors = nil
+	e.EditMux.Unlock()
+	p.members = nil
+	p.Layout.Members = nil
+	p.Layout.Cursors = make(map[string]client.Cursor, 0)
+	p.Layout.Redraw = true
+
+	p.Pos = 0
+	for p.Pos < pos {
+		p.step()
+	}
+}
+
+func (p *Player) Status() string {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	state := "playing"
+	if p.Pos == len(p.Records) {
+		state = "finished"
+	} else if p.Paused {
+		state = "paused"
+	}
+
+	at := ""
+	if p.Pos > 0 {
+		at = time.UnixMilli(p.Records[p.Pos-1].Timestamp).Format("15:04:05")
+	}
+	return fmt.Sprintf("Replay %d/%d x%g %s %s", p.Pos, len(p.Records), p.Speed, state, at)
+}
+
+func (p *Player) Bind(gui *gocui.Gui) error {
+	bindings := map[interface{}]func(){
+		gocui.KeySpace: func() {
+			p.Paused = !p.Paused
+		},
+		gocui.KeyArrowRight: func() {
+			p.Paused = true
+			if p.Pos < len(p.Records) {
+				p.step()
+			}
+		},
+		gocui.KeyArrowLeft: func() {
+			p.Paused = true
+			p.seek(p.Pos - 1)
+		},
+		']': func() {
+			p.seek(p.Pos + len(p.Records)/10 + 1)
+		},
+		'[': func() {
+			p.seek(p.Pos - len(p.Records)/10 - 1)
+		},
+		gocui.KeyHome: func() {
+			p.seek(0)
+		},
+		gocui.KeyEnd: func() {
+			p.seek(len(p.Records))
+		},
+		'+': func() {
+			p.Speed *= 2
+		},
+		'-': func() {
+			p.Speed /= 2
+		},
+	}
+
+	for key, action := range bindings {
+		action := action
+		err := gui.SetKeybinding("editor", key, gocui.ModNone, func(gui *gocui.Gui, v *gocui.View) error {
+			p.mux.Lock()
+			action()
+			p.mux.Unlock()
+			p.poke()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
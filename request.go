@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// requestSave asks the host to save the document to their disk. The host
+// saving for themselves is just a save.
+func (l *Layout) requestSave(gui *gocui.Gui, v *gocui.View) error {
+	if l.isHost() {
+		l.Save = &Save{Editor: l.Editor}
+		return nil
+	}
+	if l.Host == "" {
+		l.Editor.Nodify("There is no host to save")
+		return nil
+	}
+	l.Editor.EditMux.Lock()
+	l.Editor.flushChanges(true)
+	l.Editor.EditMux.Unlock()
+	l.Editor.Queue <- &client.SaveRequest{}
+	l.Editor.Nodify("Asked " + l.authorName(l.Host) + " to save")
+	return nil
+}
+
+// handleSaveRequest asks the host whether to save for a member. Members can
+// choose their own names and ClientIDs, so none of them is trusted to save
+// without asking. The host can allow a member to save for as long as they stay
+// connected, which goes by the connection Ably gave them rather than anything
+// they chose.
+func (l *Layout) handleSaveRequest(msg *ably.Message) {
+	if !l.isHost() || msg.ClientID == l.Id {
+		return
+	}
+	if l.Allowed[msg.ConnectionID] {
+		l.saveFor(msg.ClientID)
+		return
+	}
+	name := l.authorName(msg.ClientID)
+
+	l.ask(&Prompt{
+		Editor: l.Editor,
+		Label:  name + " asks you to save. Save? (y/n/a=always while connected)",
+		OnEnter: func(value string) error {
+			switch value {
+			case "y", "yes":
+				l.saveFor(msg.ClientID)
+			case "a", "always":
+				if l.Allowed == nil {
+					l.Allowed = make(map[string]bool)
+				}
+				if msg.ConnectionID != "" {
+					l.Allowed[msg.ConnectionID] = true
+				}
+				l.saveFor(msg.ClientID)
+			case "n", "no", "":
+				l.Editor.Queue <- &client.SaveResult{Client: msg.ClientID, Error: "The host declined to save"}
+			default:
+				return errors.New("Type y to save, a to always save for them or n to decline")
+			}
+			return nil
+		},
+	})
+}
+
+func (l *Layout) saveFor(id string) {
+	result := &client.SaveResult{Client: id, File: l.FileName}
+	if l.FileName == "" {
+		result.Error = "The host hasn't chosen a file name yet"
+		l.Editor.Nodify(l.authorName(id) + " asked you to save, " + l.hint("save-as", "choose a file name"))
+	} else {
+		err := (&Save{Editor: l.Editor}).Save()
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	l.Editor.Queue <- result
+}
+
+func (l *Layout) handleSaveResult(msg *ably.Message) {
+	var result client.SaveResult
+	err := client.Decode(msg, &result)
+	if err != nil || result.Client != l.Id {
+		return
+	}
+	if result.Error != "" {
+		l.Editor.Nodify("Save failed: " + result.Error)
+	} else {
+		l.Editor.Nodify(l.authorName(msg.ClientID) + " saved " + result.File)
+	}
+}
+
+// handleSaved tells members when the host saves, so they know the file on
+// disk is up to date, and takes what was saved as the text they compare
+// against to show unsaved changes.
+func (l *Layout) handleSaved(msg *ably.Message) {
+	var saved client.Saved
+	err := client.Decode(msg, &saved)
+	if err != nil || msg.ClientID == l.Id {
+		return
+	}
+	var hash [sha256.Size]byte
+	if b, err := hex.DecodeString(saved.Hash); err == nil && len(b) == len(hash) {
+		copy(hash[:], b)
+		l.Editor.EditMux.Lock()
+		l.Editor.Saved = &hash
+		l.Editor.Edited = false
+		l.Editor.EditMux.Unlock()
+	}
+	l.Editor.Nodify(l.authorName(msg.ClientID) + " saved " + saved.File)
+}
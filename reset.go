@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// resetVote is a request to reset the document that hasn't been decided yet.
+type resetVote struct {
+	From    string
+	Request client.ResetRequest
+	Agree   map[string]bool
+	Prompt  *Prompt
+	Decided bool
+}
+
+func resetAction(request client.ResetRequest) string {
+	switch {
+	case request.Open != "":
+		return "replace the document with " + request.Open
+	case request.Restore:
+		return "restore the previous document"
+	}
+	return "clear the document"
+}
+
+// requestReset clears the document, or restores the one from before the last
+// reset. The host just confirms, anyone else has to ask.
+func (l *Layout) requestReset(restore bool) func(*gocui.Gui, *gocui.View) error {
+	return func(gui *gocui.Gui, v *gocui.View) error {
+		if l.Ended {
+			return nil
+		}
+		if l.isHost() {
+			l.ask(&Prompt{
+				Editor: l.Editor,
+				Label:  "Really " + resetAction(client.ResetRequest{Restore: restore}) + " for everyone? (y/n)",
+				OnEnter: func(value string) error {
+					switch value {
+					case "y", "yes":
+						l.Editor.reset("", restore)
+					case "n", "no", "":
+					default:
+						return errors.New("Type y to go ahead or n to cancel")
+					}
+					return nil
+				},
+			})
+			return nil
+		}
+
+		request := &client.ResetRequest{Id: makeTag(), Restore: restore}
+		l.Editor.Queue <- request
+		l.Editor.Nodify("Asked everyone to " + resetAction(*request))
+		return nil
+	}
+}
+
+// reset publishes the "new" message for a reset that has been agreed, along
+// with the result approving the request with the given ID, or an empty one
+// when the host resets without asking. Only the host calls it, and everyone
+// ignores a "new" without an approving result straight before it, so replays
+// of the history match what members saw.
+func (e *Editor) reset(id string, restore bool) {
+	e.EditMux.Lock()
+	e.flushChanges(true)
+	e.EditMux.Unlock()
+
+	if !restore {
+		e.Queue <- replace(id, "")
+		return
+	}
+	go func() {
+		text, err := client.PreviousText(context.Background(), e.Channel)
+		if err != nil {
+			e.Nodify(err.Error())
+			e.Queue <- &client.ResetResult{Id: id, Approved: false}
+			return
+		}
+		e.Queue <- replace(id, string(bytes.Join(text, []byte{'\n'})))
+	}()
+}
+
+// replace returns the approving result and the "new" that replaces the
+// document with text, queued as one so that they are published together.
+func replace(id string, text string) []interface{} {
+	return []interface{}{&client.ResetResult{Id: id, Approved: true}, &ably.Message{Name: "new", Data: text}}
+}
+
+// handleResetRequest asks everyone but the member who sent the request
+// whether to go ahead.
+func (l *Layout) handleResetRequest(msg *ably.Message) {
+	var request client.ResetRequest
+	err := client.Decode(msg, &request)
+	if err != nil {
+		return
+	}
+	if l.Resets == nil {
+		l.Resets = make(map[string]*resetVote)
+	}
+	vote := &resetVote{From: msg.ClientID, Request: request, Agree: map[string]bool{msg.ClientID: true}}
+	l.Resets[request.Id] = vote
+	if msg.ClientID != l.Id {
+		question := "Agree?"
+		if l.isHost() {
+			question = "Allow it?"
+		}
+		vote.Prompt = &Prompt{
+			Editor: l.Editor,
+			Label:  l.authorName(msg.ClientID) + " wants to " + resetAction(request) + ". " + question + " (y/n)",
+			OnEnter: func(value string) error {
+				switch value {
+				case "y", "yes":
+					l.Editor.Queue <- &client.ResetVote{Id: request.Id, Agree: true}
+				case "n", "no":
+					l.Editor.Queue <- &client.ResetVote{Id: request.Id, Agree: false}
+				default:
+					return errors.New("Type y to agree or n to disagree")
+				}
+				return nil
+			},
+		}
+		l.ask(vote.Prompt)
+	}
+	l.tally(request.Id, vote)
+}
+
+// handleResetVote records a member's vote. Everyone keeps count, so that
+// whoever takes over as host can carry on deciding.
+func (l *Layout) handleResetVote(msg *ably.Message) {
+	var ballot client.ResetVote
+	err := client.Decode(msg, &ballot)
+	if err != nil {
+		return
+	}
+	vote, ok := l.Resets[ballot.Id]
+	if !ok {
+		return
+	}
+	vote.Agree[msg.ClientID] = ballot.Agree
+	l.tally(ballot.Id, vote)
+}
+
+// tallyResets decides any requests that can be decided now that members have
+// come or gone.
+func (l *Layout) tallyResets() {
+	for id, vote := range l.Resets {
+		l.tally(id, vote)
+	}
+}
+
+// tally has the host decide a request once they have voted on it, once most
+// members agree, or once that can no longer happen, including when the member
+// who asked has left.
+func (l *Layout) tally(id string, vote *resetVote) {
+	if !l.isHost() || vote.Decided {
+		return
+	}
+	if agree, ok := vote.Agree[l.Id]; ok && vote.From != l.Id {
+		l.decideReset(id, vote, agree)
+		return
+	}
+	if !l.present(vote.From) {
+		l.decideReset(id, vote, false)
+		return
+	}
+
+	members, agree, disagree := 0, 0, 0
+	for _, member := range l.Members {
+		if client.DecodePresence(member).Role == "bot" {
+			continue
+		}
+		members++
+		if agreed, ok := vote.Agree[member.ClientID]; ok && agreed {
+			agree++
+		} else if ok {
+			disagree++
+		}
+	}
+	if agree*2 > members {
+		l.decideReset(id, vote, true)
+	} else if disagree*2 >= members {
+		l.decideReset(id, vote, false)
+	}
+}
+
+// decideReset publishes the result of a request, and carries it out if it was
+// approved, in which case the result goes with the "new". Only the host who
+// asked to open a file has its contents, so anyone who has taken over since
+// turns it down.
+func (l *Layout) decideReset(id string, vote *resetVote, approved bool) {
+	vote.Decided = true
+	open, ok := l.Opens[id]
+	if vote.Request.Open != "" && !ok {
+		approved = false
+	}
+	if approved && vote.Request.Open != "" {
+		l.Editor.load(id, open.Name, open.Data)
+	} else if approved {
+		l.Editor.reset(id, vote.Request.Restore)
+	} else {
+		l.Editor.Queue <- &client.ResetResult{Id: id, Approved: false}
+	}
+}
+
+// handleResetResult closes the question about a request once it's decided,
+// and forgets the request.
+func (l *Layout) handleResetResult(msg *ably.Message) {
+	var result client.ResetResult
+	err := client.Decode(msg, &result)
+	if err != nil || msg.ClientID != l.Host {
+		return
+	}
+	vote, ok := l.Resets[result.Id]
+	if !ok {
+		return
+	}
+	delete(l.Resets, result.Id)
+	delete(l.Opens, result.Id)
+	if vote.Prompt != nil {
+		vote.Prompt.Close()
+	}
+
+	if result.Approved {
+		l.Editor.Nodify("Agreed to " + resetAction(vote.Request))
+	} else if vote.From == l.Id {
+		l.Editor.Nodify("Not allowed to " + resetAction(vote.Request))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/ably/ably-go/ably"
+	"github.com/jroimartin/gocui"
+)
+
+// testLayout returns a layout for the client id, whose editor queues what it
+// would publish instead of publishing it.
+func testLayout(id string, members ...*ably.PresenceMessage) *Layout {
+	l := &Layout{Id: id, Host: "host", Members: members}
+	l.Editor = &Editor{
+		Layout:    l,
+		Gui:       &gocui.Gui{},
+		Queue:     make(chan interface{}, 10),
+		PresQueue: make(chan client.Presence, 1),
+	}
+	return l
+}
+
+// published describes what the editor has queued to publish, in order.
+func published(e *Editor) []string {
+	var got []string
+	var describe func(msg interface{})
+	describe = func(msg interface{}) {
+		switch msg := msg.(type) {
+		case []interface{}:
+			for _, m := range msg {
+				describe(m)
+			}
+		case *client.ResetResult:
+			got = append(got, fmt.Sprintf("reset-result %s %v", msg.Id, msg.Approved))
+		case *ably.Message:
+			got = append(got, fmt.Sprintf("%s %q", msg.Name, msg.Data))
+		case *client.Meta:
+			got = append(got, "meta "+msg.File)
+		}
+	}
+	for {
+		select {
+		case msg := <-e.Queue:
+			describe(msg)
+		default:
+			return got
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	everyone := []*ably.PresenceMessage{member("host", "host"), member("alice", "member"), member("bob", "member"), member("carol", "member")}
+	tests := []struct {
+		name    string
+		id      string
+		members []*ably.PresenceMessage
+		from    string
+		agree   map[string]bool
+		want    []string
+	}{
+		{
+			"host agrees",
+			"host", everyone, "alice", map[string]bool{"alice": true, "host": true},
+			[]string{"reset-result r true", `new ""`},
+		},
+		{
+			"host disagrees",
+			"host", everyone, "alice", map[string]bool{"alice": true, "bob": true, "carol": true, "host": false},
+			[]string{"reset-result r false"},
+		},
+		{
+			"most agree",
+			"host", everyone, "alice", map[string]bool{"alice": true, "bob": true, "carol": true},
+			[]string{"reset-result r true", `new ""`},
+		},
+		{
+			"half disagree",
+			"host", everyone, "alice", map[string]bool{"alice": true, "bob": false, "carol": false},
+			[]string{"reset-result r false"},
+		},
+		{
+			"still voting",
+			"host", everyone, "alice", map[string]bool{"alice": true, "bob": true},
+			nil,
+		},
+		{
+			"bots don't vote",
+			"host", append([]*ably.PresenceMessage{member("bot1", "bot"), member("bot2", "bot")}, everyone[:3]...), "alice", map[string]bool{"alice": true, "bob": true},
+			[]string{"reset-result r true", `new ""`},
+		},
+		{
+			"asker left",
+			"host", everyone[1:], "dave", map[string]bool{"dave": true},
+			[]string{"reset-result r false"},
+		},
+		{
+			"only the host decides",
+			"bob", everyone, "alice", map[string]bool{"alice": true, "bob": true, "carol": true, "host": true},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := testLayout(test.id, test.members...)
+			vote := &resetVote{From: test.from, Agree: test.agree}
+			l.tally("r", vote)
+			got := published(l.Editor)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if vote.Decided != (test.want != nil) {
+				t.Errorf("got decided %v", vote.Decided)
+			}
+
+			// Deciding again would publish another result.
+			l.tally("r", vote)
+			if again := published(l.Editor); again != nil {
+				t.Errorf("decided again: %q", again)
+			}
+		})
+	}
+}
+
+func TestDecideResetOpen(t *testing.T) {
+	l := testLayout("host", member("host", "host"), member("alice", "member"))
+	l.Opens = map[string]opening{"r": {Name: "/tmp/notes.txt", Data: []byte("hello")}}
+	l.decideReset("r", &resetVote{From: "host", Request: client.ResetRequest{Id: "r", Open: "notes.txt"}}, true)
+	want := []string{"reset-result r true", `new "hello"`, "meta notes.txt"}
+	if got := published(l.Editor); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if l.FileName != "/tmp/notes.txt" {
+		t.Errorf("got file name %q, want the opened file", l.FileName)
+	}
+}
+
+func TestDecideResetOpenWithoutFile(t *testing.T) {
+	// A host who took over after the request was made doesn't have the file.
+	l := testLayout("host", member("host", "host"), member("alice", "member"))
+	l.decideReset("r", &resetVote{From: "old", Request: client.ResetRequest{Id: "r", Open: "notes.txt"}}, true)
+	want := []string{"reset-result r false"}
+	if got := published(l.Editor); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
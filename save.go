@@ -1,24 +1,34 @@
 package main
 
 import (
-	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/ably-labs/sync-edit/client"
 	"github.com/jroimartin/gocui"
 )
 
 type Save struct {
-	Editor *Editor
-	Force  bool
+	Editor    *Editor
+	Force     bool
+	Quit      bool
+	Overwrite bool
+	Open      bool
+	Name      string
+	Confirmed string
+	Message   string
 }
 
 func (s *Save) Layout(gui *gocui.Gui) error {
 	maxX, maxY := gui.Size()
-	var input, label *gocui.View
+	var input, label, message *gocui.View
 
-	_, err := gui.SetView("save-box", maxX/2-33, maxY/2-3, maxX/2+33, maxY/2+3)
+	_, err := gui.SetView("save-box", maxX/2-33, maxY/2-3, maxX/2+33, maxY/2+5)
 	if err != nil {
 		if err != gocui.ErrUnknownView {
 			return err
@@ -30,7 +40,11 @@ func (s *Save) Layout(gui *gocui.Gui) error {
 		if err != gocui.ErrUnknownView {
 			return err
 		}
-		fmt.Fprint(label, "Enter Filename:")
+		if s.Open {
+			fmt.Fprint(label, "Open File:")
+		} else {
+			fmt.Fprint(label, "Enter Filename:")
+		}
 		label.Frame = false
 
 	}
@@ -40,42 +54,186 @@ func (s *Save) Layout(gui *gocui.Gui) error {
 		if err != gocui.ErrUnknownView {
 			return err
 		}
-		fmt.Fprint(input, s.Editor.Layout.FileName)
-		input.SetCursor(len(s.Editor.Layout.FileName), 0)
+		name := s.Name
+		if name == "" {
+			name = s.Editor.Layout.FileName
+		}
+		if name == "" {
+			name = s.Editor.Meta.File
+		}
+		if s.Open && s.Name == "" && name != "" {
+			name = filepath.Dir(name) + string(filepath.Separator)
+		}
+		fmt.Fprint(input, name)
+		input.SetCursor(len(name), 0)
 	}
 	input.Editor = s
 	input.Editable = true
 
+	message, err = gui.SetView("save-message", maxX/2-30, maxY/2+2, maxX/2+30, maxY/2+4)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		message.Frame = false
+	}
+	message.Clear()
+	fmt.Fprint(message, s.Message)
+
 	gui.SetCurrentView("save-input")
 	return nil
 }
 
 func (s *Save) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
 	switch {
+	case key == gocui.KeyEnter && s.Open:
+		s.open(strings.TrimSpace(v.Buffer()))
 	case key == gocui.KeyEnter:
-		s.Editor.Layout.FileName = strings.TrimSpace(v.Buffer())
-		err := s.Save()
-		if err != nil {
-			label, _ := s.Editor.Gui.View("save-label")
-			label.Clear()
-			fmt.Fprint(label, err)
+		s.submit(strings.TrimSpace(v.Buffer()))
+	case key == gocui.KeyTab:
+		s.complete(v)
+	case key == gocui.KeyEsc:
+		s.Close()
+	default:
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+		s.Message = ""
+	}
+}
+
+// submit saves to name, first asking for Enter to be pressed again if that
+// means overwriting another file or creating its directory.
+func (s *Save) submit(name string) {
+	if name == "" {
+		s.Message = "Enter a file name"
+		return
+	}
+	name = expandHome(name)
+	l := s.Editor.Layout
+
+	if s.Confirmed != name {
+		dir := filepath.Dir(name)
+		info, err := os.Stat(name)
+		if err == nil && info.IsDir() {
+			s.Message = name + " is a directory"
+			return
+		} else if err == nil && name != l.FileName {
+			s.Confirmed = name
+			s.Message = filepath.Base(name) + " exists, Enter to overwrite it"
+			return
+		}
+		_, err = os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			s.Confirmed = name
+			s.Message = dir + " doesn't exist, Enter to create it"
 			return
 		}
-		s.Editor.Gui.DeleteView("save-box")
-		s.Editor.Gui.DeleteView("save-input")
-		s.Editor.Gui.DeleteView("save-label")
-		s.Editor.Layout.Save = nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		s.Message = err.Error()
+		return
+	}
+
+	previous := l.FileName
+	l.FileName = name
+	s.Overwrite = s.Overwrite || s.Confirmed == name
+	err = s.Save()
+	if err == errChangedOnDisk {
+		s.Close()
+		return
+	} else if err != nil {
+		l.FileName = previous
+		s.Message = err.Error()
+		return
+	}
+	s.Close()
+}
+
+// complete completes the file name being typed as far as it can, listing the
+// choices if there are several.
+func (s *Save) complete(v *gocui.View) {
+	typed, matches, err := completePath(strings.TrimSpace(v.Buffer()))
+	switch {
+	case err != nil:
+		s.Message = err.Error()
+		return
+	case len(matches) == 0:
+		s.Message = "No matches"
+		return
+	case len(matches) == 1:
+		s.Message = ""
 	default:
-		gocui.DefaultEditor.Edit(v, key, ch, mod)
+		s.Message = strings.Join(matches, " ")
 	}
+	v.Clear()
+	fmt.Fprint(v, typed)
+	v.SetCursor(len(typed), 0)
 }
 
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func (s *Save) Close() {
+	s.Editor.Gui.DeleteView("save-box")
+	s.Editor.Gui.DeleteView("save-input")
+	s.Editor.Gui.DeleteView("save-label")
+	s.Editor.Gui.DeleteView("save-message")
+	s.Editor.Layout.Save = nil
+}
+
+// Save writes the document to Layout.FileName. If the file has changed on disk
+// since it was opened or last saved, it asks before overwriting it.
 func (s *Save) Save() error {
-	err := os.WriteFile(s.Editor.Layout.FileName, bytes.Join(s.Editor.Text, []byte{'\n'}), 0644)
+	name := s.Editor.Layout.FileName
+	if !s.Overwrite && s.Editor.changedOnDisk(name) {
+		s.confirmOverwrite()
+		return errChangedOnDisk
+	}
+
+	data := s.Editor.Meta.Join(s.Editor.Text)
+	err := writeFile(name, data, s.Editor.Layout.Config.backup())
 	if err != nil {
 		s.Editor.Nodify(err.Error())
 	} else {
 		s.Editor.Nodify("Saved")
+		s.Editor.markSaved()
+		s.Editor.onDisk(name, data)
+		s.Editor.UpdatePresence(func(p *client.Presence) { p.File = s.Editor.Layout.FileName })
+		if s.Editor.Layout.isHost() {
+			s.Editor.renamed(s.Editor.Layout.FileName)
+			hash := textHash(s.Editor.Text)
+			s.Editor.Queue <- &client.Saved{File: filepath.Base(s.Editor.Layout.FileName), Hash: hex.EncodeToString(hash[:])}
+		}
+		if s.Quit {
+			s.Editor.Gui.Update(quit)
+		}
 	}
 	return err
 }
+
+func (s *Save) confirmOverwrite() {
+	l := s.Editor.Layout
+	l.ask(&Prompt{
+		Editor: s.Editor,
+		Label:  filepath.Base(l.FileName) + " has changed on disk. Overwrite it? (y/n)",
+		OnEnter: func(value string) error {
+			switch value {
+			case "y", "yes":
+				(&Save{Editor: s.Editor, Quit: s.Quit, Overwrite: true}).Save()
+			case "n", "no", "":
+			default:
+				return errors.New("Type y to overwrite or n to cancel")
+			}
+			return nil
+		},
+	})
+}
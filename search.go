@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/jroimartin/gocui"
+)
+
+type match struct {
+	Line  int
+	Start int
+	End   int
+}
+
+// Search finds text in the document as it is typed, optionally replacing it.
+// Matches are found again whenever the text changes, so they follow other
+// members' edits.
+type Search struct {
+	Editor    *Editor
+	Regex     bool
+	Replacing bool
+	Field     string
+	Query     string
+	Replace   string
+	Matches   []match
+	Current   int
+	From      client.Cursor
+	Err       error
+	// Found is what the matches were last found for.
+	Found searchKey
+}
+
+// searchKey identifies a search of one version of the text.
+type searchKey struct {
+	Version int
+	Query   string
+	Regex   bool
+}
+
+func (l *Layout) openSearch(gui *gocui.Gui, v *gocui.View) error {
+	if l.Search != nil {
+		return nil
+	}
+	x, y := l.Editor.cursorPos()
+	l.Search = &Search{Editor: l.Editor, Field: "search-input", From: client.Cursor{X: x, Y: y}}
+	return nil
+}
+
+func (s *Search) compile() (*regexp.Regexp, error) {
+	if s.Regex {
+		return regexp.Compile(s.Query)
+	}
+	return regexp.Compile(regexp.QuoteMeta(s.Query))
+}
+
+// find updates the matches in the text as it is shown, leaving out empty ones
+// as there's nothing to highlight or replace. It does nothing if neither the
+// text nor the search has changed since they were last found.
+func (s *Search) find() {
+	e := s.Editor
+	e.EditMux.Lock()
+	defer e.EditMux.Unlock()
+	key := searchKey{Version: e.Version, Query: s.Query, Regex: s.Regex}
+	if key == s.Found {
+		return
+	}
+	s.Found = key
+
+	s.Matches, s.Err = nil, nil
+	if s.Query == "" {
+		return
+	}
+	re, err := s.compile()
+	if err != nil {
+		s.Err = err
+		return
+	}
+	for y, line := range e.shownText() {
+		for _, loc := range re.FindAllIndex(line, -1) {
+			if loc[0] != loc[1] {
+				s.Matches = append(s.Matches, match{Line: y, Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+	if s.Current >= len(s.Matches) {
+		s.Current = 0
+	}
+}
+
+// jump moves the cursor to the i'th match.
+func (s *Search) jump(i int) {
+	if len(s.Matches) == 0 {
+		return
+	}
+	s.Current = (i + len(s.Matches)) % len(s.Matches)
+	m := s.Matches[s.Current]
+
+	e := s.Editor
+	e.EditMux.Lock()
+	e.flushChanges(false)
+	xo, yo := e.View().Origin()
+	e.moveTo(m.Start, m.Line, xo, yo)
+	e.EditMux.Unlock()
+}
+
+// first jumps to the first match at or after where the search started.
+func (s *Search) first() {
+	for i, m := range s.Matches {
+		if m.Line > s.From.Y || m.Line == s.From.Y && m.Start >= s.From.X {
+			s.jump(i)
+			return
+		}
+	}
+	s.jump(0)
+}
+
+// ops returns the ops that replace every match in text, or just the current
+// one, last first so that each leaves the positions of the ones before it
+// alone.
+func (s *Search) ops(all bool, text [][]byte) []interface{} {
+	re, err := s.compile()
+	if err != nil || len(s.Matches) == 0 {
+		return nil
+	}
+	current := s.Matches[s.Current]
+
+	var ops []interface{}
+	for y := len(text) - 1; y >= 0; y-- {
+		locs := re.FindAllSubmatchIndex(text[y], -1)
+		for i := len(locs) - 1; i >= 0; i-- {
+			loc := locs[i]
+			if loc[0] == loc[1] || !all && (y != current.Line || loc[0] != current.Start) {
+				continue
+			}
+			with := s.Replace
+			if s.Regex {
+				with = string(re.Expand(nil, []byte(s.Replace), text[y], loc))
+			}
+			ops = append(ops, &client.Delete{Line: y, Pos: loc[0], Count: loc[1] - loc[0]})
+			if with != "" {
+				ops = append(ops, &client.Add{Line: y, Pos: loc[0], Text: with})
+			}
+		}
+	}
+	return ops
+}
+
+// replace replaces the current match, or all of them. Replacing all of them is
+// published as one batch so that everyone sees a single change.
+func (s *Search) replace(all bool) {
+	e := s.Editor
+	if !e.Layout.Editable || e.Layout.Ended || e.Layout.locked() {
+		return
+	}
+	s.find()
+	e.EditMux.Lock()
+	e.flushChanges(false)
+	ops := s.ops(all, e.shownText())
+	e.send(ops...)
+	e.EditMux.Unlock()
+	if len(ops) == 0 {
+		return
+	}
+
+	e.Layout.Redraw = true
+	replaced, added := 0, 0
+	for _, op := range ops {
+		switch op := op.(type) {
+		case *client.Delete:
+			replaced++
+		case *client.Add:
+			added = len(op.Text)
+		}
+	}
+	current := s.Matches[s.Current]
+	s.find()
+	if all {
+		e.Nodify(fmt.Sprintf("Replaced %d matches", replaced))
+	} else {
+		s.From = client.Cursor{X: current.Start + added, Y: current.Line}
+		s.first()
+	}
+}
+
+func (s *Search) Layout(gui *gocui.Gui) error {
+	maxX, maxY := gui.Size()
+	s.find()
+
+	input, err := gui.SetView("search-input", 0, maxY-6, maxX/2, maxY-4)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		input.Editable = true
+		input.Editor = s
+		fmt.Fprint(input, s.Query)
+		input.SetCursor(len(s.Query), 0)
+	}
+	input.Title = "Search"
+	if s.Regex {
+		input.Title += " (regex)"
+	}
+	if s.Err != nil {
+		input.Title += ": " + s.Err.Error()
+	} else if len(s.Matches) != 0 {
+		input.Title += fmt.Sprintf(" %d/%d", s.Current+1, len(s.Matches))
+	} else if s.Query != "" {
+		input.Title += " no matches"
+	}
+
+	if s.Replacing {
+		replace, err := gui.SetView("replace-input", 0, maxY-9, maxX/2, maxY-7)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			replace.Editable = true
+			replace.Editor = s
+			replace.Title = "Replace with (Enter for one, " + s.Editor.Layout.hint("replace-all", "replace all") + ")"
+		}
+	}
+
+	gui.SetCurrentView(s.Field)
+	return nil
+}
+
+func (s *Search) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	switch {
+	case key == gocui.KeyEsc:
+		s.Close()
+	case key == gocui.KeyEnter && s.Field == "replace-input":
+		s.replace(false)
+	case key == gocui.KeyEnter || key == gocui.KeyArrowDown:
+		s.jump(s.Current + 1)
+	case key == gocui.KeyArrowUp:
+		s.jump(s.Current - 1)
+	case key == gocui.KeyTab:
+		s.Replacing = true
+		if s.Field == "search-input" {
+			s.Field = "replace-input"
+		} else {
+			s.Field = "search-input"
+		}
+	case key == gocui.KeyCtrlR:
+		s.Regex = !s.Regex
+		s.find()
+		s.first()
+	default:
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+		text := strings.TrimRight(v.Buffer(), "\n")
+		if s.Field == "replace-input" {
+			s.Replace = text
+			return
+		}
+		s.Query = text
+		s.find()
+		s.first()
+	}
+}
+
+func (s *Search) Close() {
+	s.Editor.Gui.DeleteView("search-input")
+	s.Editor.Gui.DeleteView("replace-input")
+	s.Editor.Layout.Search = nil
+}
+
+// layoutMatches highlights the matches in view, the current one in yellow.
+func (l *Layout) layoutMatches(gui *gocui.Gui, editor *gocui.View) error {
+	left, top, _, _, err := gui.ViewPosition("editor")
+	if err != nil {
+		return err
+	}
+	xo, yo := editor.Origin()
+	xs, ys := editor.Size()
+	lines := editor.BufferLines()
+
+	wanted := make(map[string]bool)
+	for i, m := range l.matches() {
+		if m.Line < yo || m.Line >= yo+ys || m.Line >= len(lines) {
+			continue
+		}
+		line := lines[m.Line]
+		sx, ex := m.Start, m.End
+		if sx < xo {
+			sx = xo
+		}
+		if ex > xo+xs {
+			ex = xo + xs
+		}
+		if ex > len(line) {
+			ex = len(line)
+		}
+		if ex <= sx {
+			continue
+		}
+
+		name := fmt.Sprintf("match-%d-%d", m.Line, m.Start)
+		wanted[name] = true
+		view, err := gui.SetView(name, left+sx-xo, top+m.Line-yo, left+ex-xo+1, top+m.Line-yo+2)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			view.Frame = false
+		}
+		view.BgColor = gocui.ColorWhite
+		if i == l.Search.Current {
+			view.BgColor = gocui.ColorYellow
+		}
+		view.FgColor = gocui.ColorBlack
+		view.Clear()
+		fmt.Fprint(view, line[sx:ex])
+	}
+
+	var stale []string
+	for _, view := range gui.Views() {
+		name := view.Name()
+		if strings.HasPrefix(name, "match-") && !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+	for _, name := range stale {
+		gui.DeleteView(name)
+	}
+	return nil
+}
+
+func (l *Layout) matches() []match {
+	if l.Search == nil {
+		return nil
+	}
+	return l.Search.Matches
+}
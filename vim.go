@@ -0,0 +1,736 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ably-labs/sync-edit/client"
+	"github.com/jroimartin/gocui"
+)
+
+const (
+	vimNormal = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+)
+
+var vimModes = []string{"NORMAL", "INSERT", "VISUAL", "VISUAL LINE"}
+
+// vimMaxCount is the largest count a command takes, so that counts can't
+// overflow or ask for more text than will fit in memory.
+const vimMaxCount = 9999
+
+type vimKey struct {
+	Key gocui.Key
+	Ch  rune
+	Mod gocui.Modifier
+}
+
+type pos struct {
+	X int
+	Y int
+}
+
+func before(a, b pos) bool {
+	return a.Y < b.Y || a.Y == b.Y && a.X < b.X
+}
+
+// Vim sits in front of the Editor, turning vim's normal and visual mode
+// commands into ops. Each command's ops are published as one batch. Insert
+// mode is just the Editor.
+type Vim struct {
+	Editor    *Editor
+	Mode      int
+	Count     int
+	Operator  rune
+	OpCount   int
+	G         bool
+	Anchor    pos
+	Register  string
+	Linewise  bool
+	keys      []vimKey
+	last      []vimKey
+	replaying bool
+	// selection is set while changing a selection, which can't be repeated.
+	selection bool
+}
+
+func (l *Layout) toggleVim(args []string) error {
+	if l.Vim != nil {
+		l.Vim = nil
+		l.Editor.Nodify("Vim mode off")
+	} else {
+		l.Vim = &Vim{Editor: l.Editor}
+		l.Editor.Nodify("Vim mode on")
+	}
+	return nil
+}
+
+func (vim *Vim) Edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	e := vim.Editor
+	if vim.Mode == vimInsert {
+		vim.record(key, ch, mod)
+		if key == gocui.KeyEsc {
+			vim.Mode = vimNormal
+			if x, _ := e.cursorPos(); x > 0 {
+				e.Edit(v, gocui.KeyArrowLeft, 0, gocui.ModNone)
+			}
+			vim.done(true)
+			return
+		}
+		e.Edit(v, key, ch, mod)
+		return
+	}
+	if e.Layout.locked() {
+		// Let the editor say why nothing happens.
+		e.Edit(v, key, ch, mod)
+		return
+	}
+
+	e.EditMux.Lock()
+	e.interact()
+	e.EditMux.Unlock()
+
+	vim.record(key, ch, mod)
+	vim.normal(v, key, ch, mod)
+}
+
+func (vim *Vim) record(key gocui.Key, ch rune, mod gocui.Modifier) {
+	if !vim.replaying {
+		vim.keys = append(vim.keys, vimKey{key, ch, mod})
+	}
+}
+
+// done finishes a command, remembering it for . if it changed the document.
+func (vim *Vim) done(change bool) {
+	if change && !vim.replaying && !vim.selection {
+		vim.last = vim.keys
+	}
+	vim.keys = nil
+	vim.selection = false
+	vim.Count, vim.Operator, vim.OpCount, vim.G = 0, 0, 0, false
+}
+
+func (vim *Vim) normal(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	l := vim.Editor.Layout
+	if mod == gocui.ModAlt {
+		// Alt moves by words and through the document as it does outside vim.
+		vim.Editor.Edit(v, key, ch, mod)
+		vim.moveTo(vim.cursor(vim.text()), vim.text())
+		vim.done(false)
+		return
+	}
+	if ch >= '1' && ch <= '9' || ch == '0' && vim.Count > 0 {
+		vim.Count = vim.Count*10 + int(ch-'0')
+		if vim.Count > vimMaxCount {
+			vim.Count = vimMaxCount
+		}
+		return
+	}
+
+	m := ch
+	switch key {
+	case gocui.KeyEsc:
+		vim.Mode = vimNormal
+		vim.done(false)
+		return
+	case gocui.KeyArrowLeft:
+		m = 'h'
+	case gocui.KeyArrowRight:
+		m = 'l'
+	case gocui.KeyArrowUp:
+		m = 'k'
+	case gocui.KeyArrowDown:
+		m = 'j'
+	case gocui.KeyHome:
+		m = '0'
+	case gocui.KeyEnd:
+		m = '$'
+	case gocui.KeyPgup, gocui.KeyPgdn:
+		vim.Editor.Edit(v, key, 0, gocui.ModNone)
+		vim.moveTo(vim.cursor(vim.text()), vim.text())
+		vim.done(false)
+		return
+	}
+	if vim.G {
+		vim.G = false
+		if m != 'g' {
+			vim.done(false)
+			return
+		}
+	} else if m == 'g' {
+		vim.G = true
+		return
+	}
+
+	text := vim.text()
+	cur := vim.cursor(text)
+	n := vim.count()
+	explicit := vim.Count > 0 || vim.OpCount > 0
+
+	if vim.Operator == 'c' && m == 'w' && charClass(text, cur) != 0 {
+		// cw changes to the end of the word, like ce, but counting the word
+		// the cursor is in even if it is on its last character.
+		to := lastChar(text, cur)
+		for i := 1; i < n; i++ {
+			to = wordEnd(text, to)
+		}
+		vim.operate('c', cur, to, false, true, text)
+		return
+	}
+	if to, linewise, inclusive, ok := vim.motion(m, n, explicit, text, cur); ok {
+		if vim.Operator == 0 {
+			vim.moveTo(to, text)
+			vim.Count, vim.G = 0, false
+			if vim.Mode == vimNormal {
+				vim.done(false)
+			}
+			return
+		}
+		vim.operate(vim.Operator, cur, to, linewise, inclusive, text)
+		return
+	}
+
+	visual := vim.Mode == vimVisual || vim.Mode == vimVisualLine
+	switch {
+	case visual && (m == 'd' || m == 'x' || m == 'y' || m == 'c'):
+		op := m
+		if op == 'x' {
+			op = 'd'
+		}
+		linewise := vim.Mode == vimVisualLine
+		vim.Mode = vimNormal
+		vim.selection = true
+		vim.operate(op, vim.Anchor, cur, linewise, true, text)
+	case m == 'd' || m == 'c' || m == 'y':
+		if vim.Operator == m {
+			vim.operate(m, pos{0, cur.Y}, pos{0, cur.Y + n - 1}, true, false, text)
+		} else if vim.Operator == 0 {
+			vim.Operator, vim.OpCount, vim.Count = m, vim.Count, 0
+		} else {
+			vim.done(false)
+		}
+	case vim.Operator != 0:
+		vim.done(false)
+	case m == 'v' || m == 'V':
+		mode := vimVisual
+		if m == 'V' {
+			mode = vimVisualLine
+		}
+		if vim.Mode == mode {
+			vim.Mode = vimNormal
+		} else {
+			vim.Mode, vim.Anchor = mode, cur
+		}
+		vim.done(false)
+	case visual:
+		vim.done(false)
+	case m == 'x' || m == 's':
+		op := 'd'
+		if m == 's' {
+			op = 'c'
+		}
+		vim.operate(op, cur, pos{cur.X + n, cur.Y}, false, false, text)
+	case m == 'X':
+		vim.operate('d', pos{cur.X - n, cur.Y}, cur, false, false, text)
+	case m == 'D' || m == 'C':
+		op := 'd'
+		if m == 'C' {
+			op = 'c'
+		}
+		to, _, _, _ := vim.motion('$', n, explicit, text, cur)
+		vim.operate(op, cur, to, false, true, text)
+	case m == 'p' || m == 'P':
+		vim.put(m == 'p', n, cur, text)
+	case m == 'i':
+		vim.insert(cur, text)
+	case m == 'a':
+		if len(text[cur.Y]) > 0 {
+			cur.X++
+		}
+		vim.insert(cur, text)
+	case m == 'I':
+		vim.insert(pos{firstNonBlank(text[cur.Y]), cur.Y}, text)
+	case m == 'A':
+		vim.insert(pos{len(text[cur.Y]), cur.Y}, text)
+	case m == 'o':
+		vim.insert(pos{len(text[cur.Y]), cur.Y}, text)
+		vim.Editor.Edit(v, gocui.KeyEnter, 0, gocui.ModNone)
+	case m == 'O':
+		vim.insert(pos{0, cur.Y}, text)
+		vim.Editor.Edit(v, gocui.KeyEnter, 0, gocui.ModNone)
+		vim.Editor.Edit(v, gocui.KeyArrowUp, 0, gocui.ModNone)
+	case m == 'J':
+		vim.join(n, cur, text)
+	case m == '.':
+		vim.repeat(v)
+	case m == 'u':
+		vim.Editor.Nodify("There's no undo in a shared document")
+		vim.done(false)
+	case m == ':':
+		vim.done(false)
+		l.openCommand("")(l.Editor.Gui, v)
+	case m == '/':
+		vim.done(false)
+		l.openSearch(l.Editor.Gui, v)
+	default:
+		vim.done(false)
+	}
+}
+
+func (vim *Vim) count() int {
+	n := vim.Count
+	if n == 0 {
+		n = 1
+	}
+	if vim.OpCount > 0 {
+		n *= vim.OpCount
+	}
+	if n > vimMaxCount {
+		n = vimMaxCount
+	}
+	return n
+}
+
+func (vim *Vim) text() [][]byte {
+	vim.Editor.EditMux.Lock()
+	defer vim.Editor.EditMux.Unlock()
+	return vim.Editor.shownText()
+}
+
+func (vim *Vim) cursor(text [][]byte) pos {
+	x, y := vim.Editor.cursorPos()
+	if y >= len(text) {
+		y = len(text) - 1
+	}
+	if x > len(text[y]) {
+		x = len(text[y])
+	}
+	return pos{x, y}
+}
+
+// moveTo puts the cursor at p, which in normal mode has to be on a character.
+func (vim *Vim) moveTo(p pos, text [][]byte) {
+	if p.Y >= len(text) {
+		p.Y = len(text) - 1
+	}
+	if p.Y < 0 {
+		p.Y = 0
+	}
+	end := len(text[p.Y])
+	if vim.Mode != vimInsert && end > 0 {
+		end--
+	}
+	if p.X > end {
+		p.X = end
+	}
+	if p.X < 0 {
+		p.X = 0
+	}
+
+	e := vim.Editor
+	e.EditMux.Lock()
+	e.flushChanges(false)
+	xo, yo := e.View().Origin()
+	e.moveTo(p.X, p.Y, xo, yo)
+	e.EditMux.Unlock()
+}
+
+func (vim *Vim) insert(p pos, text [][]byte) {
+	vim.Mode = vimInsert
+	vim.moveTo(p, text)
+}
+
+// publish sends ops made from text as one batch, after any edit still being
+// typed. If the text has changed since, as someone else's edit has come in,
+// the ops would land in the wrong place, so it sends nothing and returns false.
+func (vim *Vim) publish(ops []interface{}, text [][]byte) bool {
+	e := vim.Editor
+	e.EditMux.Lock()
+	defer e.EditMux.Unlock()
+	e.flushChanges(false)
+	shown := e.shownText()
+	if len(shown) != len(text) {
+		e.Nodify("The document changed, try again")
+		return false
+	}
+	for y := range shown {
+		if !bytes.Equal(shown[y], text[y]) {
+			e.Nodify("The document changed, try again")
+			return false
+		}
+	}
+	e.send(ops...)
+	return true
+}
+
+// motion returns where a motion goes from cur, whether it moves by whole lines
+// and whether an operator should include the character it ends on.
+func (vim *Vim) motion(m rune, n int, explicit bool, text [][]byte, cur pos) (pos, bool, bool, bool) {
+	last := len(text) - 1
+	clampY := func(y int) int {
+		if y < 0 {
+			return 0
+		} else if y > last {
+			return last
+		}
+		return y
+	}
+
+	switch m {
+	case 'h':
+		cur.X -= n
+		if cur.X < 0 {
+			cur.X = 0
+		}
+		return cur, false, false, true
+	case 'l', ' ':
+		cur.X += n
+		if cur.X > len(text[cur.Y]) {
+			cur.X = len(text[cur.Y])
+		}
+		return cur, false, false, true
+	case 'j':
+		cur.Y = clampY(cur.Y + n)
+		return cur, true, false, true
+	case 'k':
+		cur.Y = clampY(cur.Y - n)
+		return cur, true, false, true
+	case '0':
+		cur.X = 0
+		return cur, false, false, true
+	case '^':
+		cur.X = firstNonBlank(text[cur.Y])
+		return cur, false, false, true
+	case '$':
+		cur.Y = clampY(cur.Y + n - 1)
+		cur.X = len(text[cur.Y]) - 1
+		if cur.X < 0 {
+			cur.X = 0
+		}
+		return cur, false, true, true
+	case 'w':
+		for i := 0; i < n; i++ {
+			from := cur
+			cur = nextWord(text, cur)
+			if i == n-1 && vim.Operator != 0 && cur.Y > from.Y && from.X < len(text[from.Y]) {
+				// An operator stops at the end of the line the last word is
+				// on, rather than taking the line break after it.
+				cur = pos{len(text[from.Y]), from.Y}
+			}
+		}
+		return cur, false, false, true
+	case 'b':
+		for i := 0; i < n; i++ {
+			cur = prevWord(text, cur)
+		}
+		return cur, false, false, true
+	case 'e':
+		for i := 0; i < n; i++ {
+			cur = wordEnd(text, cur)
+		}
+		return cur, false, true, true
+	case 'G', 'g':
+		y := 0
+		if explicit {
+			y = n - 1
+		} else if m == 'G' {
+			y = last
+		}
+		y = clampY(y)
+		return pos{firstNonBlank(text[y]), y}, true, false, true
+	}
+	return cur, false, false, false
+}
+
+// operate applies d, c or y to the text between start and end.
+func (vim *Vim) operate(op rune, start, end pos, linewise, inclusive bool, text [][]byte) {
+	if before(end, start) {
+		start, end = end, start
+	}
+	if end.Y >= len(text) {
+		end.Y = len(text) - 1
+	}
+	if start.X < 0 {
+		start.X = 0
+	}
+
+	if linewise {
+		var lines []string
+		for y := start.Y; y <= end.Y; y++ {
+			lines = append(lines, string(text[y]))
+		}
+		vim.Register, vim.Linewise = strings.Join(lines, "\n"), true
+	} else {
+		if inclusive {
+			end.X++
+		}
+		if end.X > len(text[end.Y]) {
+			end.X = len(text[end.Y])
+		}
+		if start.X > len(text[start.Y]) {
+			start.X = len(text[start.Y])
+		}
+		vim.Register, vim.Linewise = textBetween(text, start, end), false
+	}
+
+	switch op {
+	case 'y':
+		vim.moveTo(start, text)
+		vim.done(false)
+	case 'd':
+		ops := deleteRange(text, start, end)
+		if linewise {
+			ops = deleteLines(text, start.Y, end.Y)
+			start.X = 0
+		}
+		if !vim.publish(ops, text) {
+			vim.done(false)
+			return
+		}
+		vim.moveTo(start, vim.text())
+		vim.done(true)
+	case 'c':
+		if linewise {
+			start.X = 0
+			end.X = len(text[end.Y])
+		}
+		if !vim.publish(deleteRange(text, start, end), text) {
+			vim.done(false)
+			return
+		}
+		vim.insert(start, vim.text())
+	}
+}
+
+// put inserts what was last deleted or yanked n times, after the cursor or
+// before it.
+func (vim *Vim) put(after bool, n int, cur pos, text [][]byte) {
+	if vim.Register == "" && !vim.Linewise {
+		vim.done(false)
+		return
+	}
+
+	var s string
+	at := cur
+	if vim.Linewise {
+		s = strings.Repeat(vim.Register+"\n", n)
+		if after {
+			at = pos{len(text[cur.Y]), cur.Y}
+			s = "\n" + s[:len(s)-1]
+			cur = pos{0, cur.Y + 1}
+		} else {
+			at = pos{0, cur.Y}
+			cur = at
+		}
+	} else {
+		s = strings.Repeat(vim.Register, n)
+		if after && len(text[cur.Y]) > 0 {
+			at.X++
+		}
+		cur = at
+	}
+
+	if !vim.publish(insertOps(at, s), text) {
+		vim.done(false)
+		return
+	}
+	vim.moveTo(cur, vim.text())
+	vim.done(true)
+}
+
+// join joins n lines, at least two, putting a space between them.
+func (vim *Vim) join(n int, cur pos, text [][]byte) {
+	if n < 2 {
+		n = 2
+	}
+	var ops []interface{}
+	line := append([]byte(nil), text[cur.Y]...)
+	for i := 1; i < n && cur.Y+i < len(text); i++ {
+		ops = append(ops, &client.Delete{Line: cur.Y, Pos: len(line), Count: 0})
+		next := text[cur.Y+i]
+		if len(line) > 0 && len(next) > 0 {
+			ops = append(ops, &client.Add{Line: cur.Y, Pos: len(line), Text: " "})
+			line = append(line, ' ')
+		}
+		line = append(line, next...)
+	}
+	vim.done(vim.publish(ops, text))
+}
+
+// repeat replays the keys of the last change.
+func (vim *Vim) repeat(v *gocui.View) {
+	vim.keys = nil
+	vim.Count = 0
+	if vim.last == nil {
+		return
+	}
+	vim.replaying = true
+	for _, k := range vim.last {
+		vim.Edit(v, k.Key, k.Ch, k.Mod)
+	}
+	vim.replaying = false
+	vim.keys = nil
+}
+
+func firstNonBlank(line []byte) int {
+	for i, c := range line {
+		if c != ' ' && c != '\t' {
+			return i
+		}
+	}
+	return 0
+}
+
+// charClass is 0 for blanks and line ends, 1 for word characters and 2 for
+// anything else.
+func charClass(text [][]byte, p pos) int {
+	if p.X >= len(text[p.Y]) {
+		return 0
+	}
+	c := text[p.Y][p.X]
+	switch {
+	case c == ' ' || c == '\t' || c == '\r':
+		return 0
+	case c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		return 1
+	}
+	return 2
+}
+
+// next and prev step through the text, with the end of each line a position
+// of its own.
+func next(text [][]byte, p pos) (pos, bool) {
+	if p.X < len(text[p.Y]) {
+		return pos{p.X + 1, p.Y}, true
+	}
+	if p.Y+1 < len(text) {
+		return pos{0, p.Y + 1}, true
+	}
+	return p, false
+}
+
+func prev(text [][]byte, p pos) (pos, bool) {
+	if p.X > 0 {
+		return pos{p.X - 1, p.Y}, true
+	}
+	if p.Y > 0 {
+		return pos{len(text[p.Y-1]), p.Y - 1}, true
+	}
+	return p, false
+}
+
+func nextWord(text [][]byte, p pos) pos {
+	ok := true
+	if c := charClass(text, p); c != 0 {
+		for ok && charClass(text, p) == c {
+			p, ok = next(text, p)
+		}
+	}
+	for ok && charClass(text, p) == 0 {
+		p, ok = next(text, p)
+	}
+	return p
+}
+
+func wordEnd(text [][]byte, p pos) pos {
+	p, ok := next(text, p)
+	for ok && charClass(text, p) == 0 {
+		p, ok = next(text, p)
+	}
+	return lastChar(text, p)
+}
+
+// lastChar returns the last character of the word, or run of blanks, at p.
+func lastChar(text [][]byte, p pos) pos {
+	c := charClass(text, p)
+	for {
+		q, ok := next(text, p)
+		if !ok || charClass(text, q) != c {
+			return p
+		}
+		p = q
+	}
+}
+
+func prevWord(text [][]byte, p pos) pos {
+	p, ok := prev(text, p)
+	for ok && charClass(text, p) == 0 {
+		p, ok = prev(text, p)
+	}
+	c := charClass(text, p)
+	for {
+		q, ok := prev(text, p)
+		if !ok || charClass(text, q) != c {
+			return p
+		}
+		p = q
+	}
+}
+
+func textBetween(text [][]byte, start, end pos) string {
+	if start.Y == end.Y {
+		return string(text[start.Y][start.X:end.X])
+	}
+	s := string(text[start.Y][start.X:])
+	for y := start.Y + 1; y < end.Y; y++ {
+		s += "\n" + string(text[y])
+	}
+	return s + "\n" + string(text[end.Y][:end.X])
+}
+
+// deleteRange returns the ops that delete the text between start and end,
+// each applying to the text as the ones before it leave it.
+func deleteRange(text [][]byte, start, end pos) []interface{} {
+	if start.Y == end.Y {
+		if end.X > start.X {
+			return []interface{}{&client.Delete{Line: start.Y, Pos: start.X, Count: end.X - start.X}}
+		}
+		return nil
+	}
+
+	var ops []interface{}
+	if n := len(text[start.Y]) - start.X; n > 0 {
+		ops = append(ops, &client.Delete{Line: start.Y, Pos: start.X, Count: n})
+	}
+	for y := start.Y + 1; y < end.Y; y++ {
+		if len(text[y]) > 0 {
+			ops = append(ops, &client.Delete{Line: start.Y + 1, Pos: 0, Count: len(text[y])})
+		}
+		ops = append(ops, &client.Delete{Line: start.Y, Pos: start.X, Count: 0})
+	}
+	if end.X > 0 {
+		ops = append(ops, &client.Delete{Line: start.Y + 1, Pos: 0, Count: end.X})
+	}
+	return append(ops, &client.Delete{Line: start.Y, Pos: start.X, Count: 0})
+}
+
+// deleteLines returns the ops that delete lines first to last, line breaks and
+// all.
+func deleteLines(text [][]byte, first, last int) []interface{} {
+	end := len(text) - 1
+	switch {
+	case last < end:
+		return deleteRange(text, pos{0, first}, pos{0, last + 1})
+	case first > 0:
+		return deleteRange(text, pos{len(text[first-1]), first - 1}, pos{len(text[last]), last})
+	}
+	return deleteRange(text, pos{0, 0}, pos{len(text[end]), end})
+}
+
+// insertOps returns the ops that insert s at p, splitting lines at newlines.
+func insertOps(p pos, s string) []interface{} {
+	var ops []interface{}
+	for i, part := range strings.Split(s, "\n") {
+		if i > 0 {
+			ops = append(ops, &client.Add{Line: p.Y, Pos: p.X})
+			p = pos{0, p.Y + 1}
+		}
+		if part != "" {
+			ops = append(ops, &client.Add{Line: p.Y, Pos: p.X, Text: part})
+			p.X += len(part)
+		}
+	}
+	return ops
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ably-labs/sync-edit/client"
+)
+
+func lines(s string) [][]byte {
+	return client.ApplyNew(s, nil)
+}
+
+// applyOps applies ops to s in order, as everyone receiving them would.
+func applyOps(s string, ops []interface{}) string {
+	text := lines(s)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case *client.Add:
+			text = client.ApplyAdd(*op, text)
+		case *client.Delete:
+			text = client.ApplyDel(*op, text)
+		}
+	}
+	return string(bytes.Join(text, []byte{'\n'}))
+}
+
+func TestDeleteRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		start, end pos
+		want       string
+	}{
+		{"within a line", "hello world", pos{2, 0}, pos{7, 0}, "heorld"},
+		{"empty", "hello", pos{2, 0}, pos{2, 0}, "hello"},
+		{"line break", "ab\ncd", pos{2, 0}, pos{0, 1}, "abcd"},
+		{"across two lines", "abc\ndef", pos{1, 0}, pos{2, 1}, "af"},
+		{"across several lines", "abc\ndef\n\nghi\njkl", pos{1, 0}, pos{1, 4}, "akl"},
+		{"to the end", "abc\ndef", pos{0, 0}, pos{3, 1}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := applyOps(test.text, deleteRange(lines(test.text), test.start, test.end))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		first, last int
+		want        string
+	}{
+		{"first", "a\nb\nc", 0, 0, "b\nc"},
+		{"middle", "a\nb\nc\nd", 1, 2, "a\nd"},
+		{"last", "a\nb\nc", 2, 2, "a\nb"},
+		{"to the end", "a\nb\nc", 1, 2, "a"},
+		{"all", "a\nb\nc", 0, 2, ""},
+		{"only", "abc", 0, 0, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := applyOps(test.text, deleteLines(lines(test.text), test.first, test.last))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInsertOps(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		at   pos
+		s    string
+		want string
+	}{
+		{"within a line", "abc", pos{1, 0}, "XY", "aXYbc"},
+		{"lines", "abc", pos{1, 0}, "X\nY", "aX\nYbc"},
+		{"line after", "abc\ndef", pos{3, 0}, "\nX", "abc\nX\ndef"},
+		{"line before", "abc\ndef", pos{0, 1}, "X\n", "abc\nX\ndef"},
+		{"blank lines", "ab", pos{1, 0}, "\n\n", "a\n\nb"},
+		{"nothing", "abc", pos{1, 0}, "", "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := applyOps(test.text, insertOps(test.at, test.s))
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// wordTest is a word motion from one place in some text.
+type wordTest struct {
+	name string
+	text string
+	from pos
+	want pos
+}
+
+func testWords(t *testing.T, motion func([][]byte, pos) pos, tests []wordTest) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := motion(lines(test.text), test.from)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNextWord(t *testing.T) {
+	testWords(t, nextWord, []wordTest{
+		{"next word", "foo bar", pos{0, 0}, pos{4, 0}},
+		{"from the middle", "foo bar", pos{1, 0}, pos{4, 0}},
+		{"from blanks", "foo   bar", pos{4, 0}, pos{6, 0}},
+		{"punctuation", "foo.bar", pos{0, 0}, pos{3, 0}},
+		{"after punctuation", "foo.bar", pos{3, 0}, pos{4, 0}},
+		{"next line", "foo\n  bar", pos{0, 0}, pos{2, 1}},
+		{"past blank lines", "foo\n\n\nbar", pos{0, 0}, pos{0, 3}},
+		{"last word", "foo bar", pos{4, 0}, pos{7, 0}},
+	})
+}
+
+func TestPrevWord(t *testing.T) {
+	testWords(t, prevWord, []wordTest{
+		{"previous word", "foo bar", pos{4, 0}, pos{0, 0}},
+		{"start of the word", "foo bar", pos{6, 0}, pos{4, 0}},
+		{"punctuation", "foo.bar", pos{4, 0}, pos{3, 0}},
+		{"previous line", "foo\n  bar", pos{2, 1}, pos{0, 0}},
+		{"first word", "foo bar", pos{0, 0}, pos{0, 0}},
+	})
+}
+
+func TestWordEnd(t *testing.T) {
+	testWords(t, wordEnd, []wordTest{
+		{"end of the word", "foo bar", pos{0, 0}, pos{2, 0}},
+		{"end of the next word", "foo bar", pos{2, 0}, pos{6, 0}},
+		{"one character", "a bc", pos{0, 0}, pos{3, 0}},
+		{"punctuation", "foo.bar", pos{0, 0}, pos{2, 0}},
+		{"next line", "foo\n  bar", pos{2, 0}, pos{4, 1}},
+		{"last word", "foo bar", pos{6, 0}, pos{7, 0}},
+	})
+}
+
+func TestLastChar(t *testing.T) {
+	testWords(t, lastChar, []wordTest{
+		{"end of the word", "foo bar", pos{0, 0}, pos{2, 0}},
+		{"one character", "a bc", pos{0, 0}, pos{0, 0}},
+		{"already there", "foo bar", pos{2, 0}, pos{2, 0}},
+	})
+}
+
+func TestOperatorWord(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		from pos
+		n    int
+		want pos
+	}{
+		{"within a line", "foo bar baz", pos{0, 0}, 2, pos{8, 0}},
+		{"last word", "foo bar\nbaz", pos{4, 0}, 1, pos{7, 0}},
+		{"last word before an indent", "foo bar\n  baz", pos{4, 0}, 1, pos{7, 0}},
+		{"across lines", "foo bar\nbaz qux", pos{4, 0}, 2, pos{4, 1}},
+		{"ending on the next line", "foo\nbar\nbaz", pos{0, 0}, 2, pos{3, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vim := &Vim{Operator: 'd'}
+			got, _, _, _ := vim.motion('w', test.n, true, lines(test.text), test.from)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}